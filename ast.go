@@ -1,221 +1,393 @@
-package main
+package aurora
 
 import "fmt"
 
-type Node interface {
-	compile()
+type node interface {
+	compile(c *compiler)
 	String() string
 }
 
-type If struct {
-	Cond Node
-	Then []Node
-	Else []Node
+// ifStmt runs Then when Cond holds, otherwise the first of ElseIfs whose
+// condition holds, otherwise Else.
+type ifStmt struct {
+	Cond    node
+	Then    []node
+	ElseIfs []elseIfClause
+	Else    []node
+	Line    int
+}
+
+func (i ifStmt) String() string {
+	return fmt.Sprintf("If(%s, %s, %s, %s)", i.Cond.String(), i.Then, i.ElseIfs, i.Else)
+}
+
+// elseIfClause is an else if or elif branch of an ifStmt.
+type elseIfClause struct {
+	Cond node
+	Then []node
 	Line int
 }
 
-func (i If) String() string {
-	return fmt.Sprintf("If(%s, %s, %s)", i.Cond.String(), i.Then, i.Else)
+func (e elseIfClause) String() string {
+	return fmt.Sprintf("ElseIf(%s, %s)", e.Cond.String(), e.Then)
 }
 
-type While struct {
-	Cond Node
-	Body []Node
+type whileStmt struct {
+	Cond node
+	Body []node
 	Line int
 }
 
-func (w While) String() string {
+func (w whileStmt) String() string {
 	return fmt.Sprintf("While(%s, %s)", w.Cond.String(), w.Body)
 }
 
-type For struct {
-	Name string
-	In   Node
-	Body []Node
-	Line int
+// forStmt binds each item to Names. With more than one name, the items are
+// lists that are unpacked into them.
+type forStmt struct {
+	Names []string
+	In    node
+	Body  []node
+	Line  int
 }
 
-func (f For) String() string {
-	return fmt.Sprintf("For(%s, %s, %s)", f.Name, f.In.String(), f.Body)
+func (f forStmt) String() string {
+	return fmt.Sprintf("For(%s, %s, %s)", f.Names, f.In.String(), f.Body)
 }
 
-type Func struct {
+type funcStmt struct {
 	Name string
 	Args []string
-	Body []Node
+	Body []node
 	Line int
 }
 
-func (f Func) String() string {
+func (f funcStmt) String() string {
 	return fmt.Sprintf("Func(%s, %s, %s)", f.Name, f.Args, f.Body)
 }
 
-type Sub struct {
+type subStmt struct {
 	Name string
 	Args []string
-	Body []Node
+	Body []node
 	Line int
 }
 
-func (s Sub) String() string {
+func (s subStmt) String() string {
 	return fmt.Sprintf("Sub(%s, %s, %s)", s.Name, s.Args, s.Body)
 }
 
-type Return struct {
-	Expr Node
+type returnStmt struct {
+	Expr node
 	Line int
 }
 
-func (r Return) String() string {
+func (r returnStmt) String() string {
 	return fmt.Sprintf("Return(%s)", r.Expr.String())
 }
 
-type Break struct {
+type breakStmt struct {
 	Line int
 }
 
-func (b Break) String() string {
+func (b breakStmt) String() string {
 	return "Break()"
 }
 
-type Continue struct {
+type continueStmt struct {
 	Line int
 }
 
-func (c Continue) String() string {
+func (c continueStmt) String() string {
 	return "Continue()"
 }
 
-type FuncCall struct {
-	Func Node
-	Args []Node
+// tryStmt has a catch block, a finally block or both. Catch is nil when there is
+// no catch block and Finally is nil when there is no finally block.
+type tryStmt struct {
+	Body      []node
+	CatchName string // empty when the error is not bound to a variable
+	Catch     []node
+	Finally   []node
+	Line      int
+}
+
+func (t tryStmt) String() string {
+	return fmt.Sprintf("Try(%s, %s, %s, %s)", t.Body, t.CatchName, t.Catch, t.Finally)
+}
+
+type throwStmt struct {
+	Expr node
+	Line int
+}
+
+func (t throwStmt) String() string {
+	return fmt.Sprintf("Throw(%s)", t.Expr.String())
+}
+
+type callExpr struct {
+	Func node
+	Args []node
 	Line int
 }
 
-func (f FuncCall) String() string {
+func (f callExpr) String() string {
 	return fmt.Sprintf("FuncCall(%s, %s)", f.Func.String(), f.Args)
 }
 
-//go:generate stringer -type=OperatorType
-type OperatorType int
+//go:generate stringer -type=operatorType
+type operatorType int
 
 const (
-	Plus OperatorType = iota
-	Minus
-	Multiply
-	Divide
-	Modulo
-	Equal
-	NotEqual
-	Less
-	LessEqual
-	Greater
-	GreaterEqual
-	And
-	Or
-	Not
-	Assign
-	PlusAssign
-	MinusAssign
-	MultiplyAssign
-	DivideAssign
+	plus operatorType = iota
+	minus
+	multiply
+	divide
+	modulo
+	equal
+	notEqual
+	less
+	lessEqual
+	greater
+	greaterEqual
+	and
+	or
+	not
+	assign
+	plusAssign
+	minusAssign
+	multiplyAssign
+	divideAssign
 )
 
-type Assignment struct {
+type assignStmt struct {
 	Left  string
-	Right Node
-	Op    OperatorType
+	Right node
+	Op    operatorType
 	Line  int
 }
 
-func (a Assignment) String() string {
+func (a assignStmt) String() string {
 	return fmt.Sprintf("Assignment(%s, %s, %s)", a.Left, a.Op.String(), a.Right.String())
 }
 
-type AssignIndex struct {
-	Left  string
-	Index Node
-	Right Node
-	Op    OperatorType
+// multiAssignStmt is a, b = x, y, or a, b = xs when Right has a single
+// expression, which must then be a list of as many items as there are names.
+type multiAssignStmt struct {
+	Names []string
+	Right []node
+	Line  int
+}
+
+func (m multiAssignStmt) String() string {
+	return fmt.Sprintf("MultiAssign(%s, %s)", m.Names, m.Right)
+}
+
+// matchStmt runs the body of the first of Cases whose pattern matches Subject
+// and whose guard, if it has one, holds.
+type matchStmt struct {
+	Subject node
+	Cases   []caseClause
+	Line    int
+}
+
+func (m matchStmt) String() string {
+	return fmt.Sprintf("Match(%s, %s)", m.Subject.String(), m.Cases)
+}
+
+// caseClause is one case of a matchStmt. Guard is nil when there is none.
+type caseClause struct {
+	Pattern pattern
+	Guard   node
+	Body    []node
+	Line    int
+}
+
+func (c caseClause) String() string {
+	return fmt.Sprintf("Case(%s, %v, %s)", c.Pattern.String(), c.Guard, c.Body)
+}
+
+// pattern is what a case in a match statement tests its subject against.
+type pattern interface {
+	// match tests the value in register, binding names as it goes, and adds
+	// to fails the jumps taken when it does not match.
+	match(c *compiler, register byte, fails *[]int)
+	String() string
+}
+
+// literalPattern matches a value equal to Value.
+type literalPattern struct {
+	Value Value
+}
+
+func (l literalPattern) String() string {
+	return fmt.Sprintf("LiteralPattern(%s)", l.Value.repr())
+}
+
+// wildcardPattern is _, which matches anything.
+type wildcardPattern struct{}
+
+func (wildcardPattern) String() string {
+	return "WildcardPattern"
+}
+
+// bindPattern matches anything and assigns it to Name.
+type bindPattern struct {
+	Name string
+}
+
+func (b bindPattern) String() string {
+	return fmt.Sprintf("BindPattern(%s)", b.Name)
+}
+
+// listPattern matches a list whose items match Items. With HasRest the list
+// may be longer, and the remaining items are assigned to Rest unless it is
+// empty.
+type listPattern struct {
+	Items   []pattern
+	HasRest bool
+	Rest    string
+}
+
+func (l listPattern) String() string {
+	return fmt.Sprintf("ListPattern(%s, %t, %s)", l.Items, l.HasRest, l.Rest)
+}
+
+// mapPattern matches a map that has every one of Keys, with values that
+// match the corresponding Values. Other keys are ignored.
+type mapPattern struct {
+	Keys   []string
+	Values []pattern
+}
+
+func (m mapPattern) String() string {
+	return fmt.Sprintf("MapPattern(%s, %s)", m.Keys, m.Values)
+}
+
+// typeDecl declares a record type with the given fields and methods. Each
+// method takes the record as an implicit first argument named self.
+type typeDecl struct {
+	Name    string
+	Fields  []string
+	Methods []funcStmt
+	Line    int
+}
+
+func (t typeDecl) String() string {
+	return fmt.Sprintf("TypeDecl(%s, %s, %s)", t.Name, t.Fields, t.Methods)
+}
+
+// methodCallExpr is Receiver.Name(Args), which calls the method Name of the
+// record Receiver with the record as self.
+type methodCallExpr struct {
+	Receiver node
+	Name     string
+	Args     []node
+	Line     int
+}
+
+func (m methodCallExpr) String() string {
+	return fmt.Sprintf("MethodCall(%s, %s, %s)", m.Receiver.String(), m.Name, m.Args)
+}
+
+// assignIndexStmt is Left:Index = Right. Left is any expression, so the
+// target of grid:y:x = v is the row grid:y.
+type assignIndexStmt struct {
+	Left  node
+	Index node
+	Right node
+	Op    operatorType
 	Line  int
 }
 
-func (a AssignIndex) String() string {
-	return fmt.Sprintf("AssignIndex(%s, %s, %s, %s)", a.Left, a.Index.String(), a.Op.String(), a.Right.String())
+func (a assignIndexStmt) String() string {
+	return fmt.Sprintf("AssignIndex(%s, %s, %s, %s)", a.Left.String(), a.Index.String(), a.Op.String(), a.Right.String())
 }
 
-type Unary struct {
-	Expr Node
-	Op   OperatorType
+type unaryExpr struct {
+	Expr node
+	Op   operatorType
 	Line int
 }
 
-func (u Unary) String() string {
+func (u unaryExpr) String() string {
 	return fmt.Sprintf("Unary(%s, %s)", u.Op.String(), u.Expr.String())
 }
 
-type Binary struct {
-	Left  Node
-	Right Node
-	Op    OperatorType
+type binaryExpr struct {
+	Left  node
+	Right node
+	Op    operatorType
 	Line  int
 }
 
-func (b Binary) String() string {
+func (b binaryExpr) String() string {
 	return fmt.Sprintf("Binary(%s, %s, %s)", b.Left.String(), b.Op.String(), b.Right.String())
 }
 
-type Number struct {
+type numberLit struct {
 	Value float64
 	Line  int
 }
 
-func (n Number) String() string {
+func (n numberLit) String() string {
 	return fmt.Sprintf("Number(%f)", n.Value)
 }
 
-type String struct {
+type stringLit struct {
 	Value string
 	Line  int
 }
 
-func (s String) String() string {
+func (s stringLit) String() string {
 	return fmt.Sprintf("String(%q)", s.Value)
 }
 
-type Bool struct {
+type boolLit struct {
 	Value bool
 	Line  int
 }
 
-func (b Bool) String() string {
+func (b boolLit) String() string {
 	return fmt.Sprintf("Bool(%t)", b.Value)
 }
 
-type List struct {
-	Values []Node
+type listLit struct {
+	Values []node
 	Line   int
 }
 
-func (l List) String() string {
+func (l listLit) String() string {
 	return fmt.Sprintf("List(%s)", l.Values)
 }
 
-type Variable struct {
+type variableExpr struct {
 	Name string
 	Line int
 }
 
-func (v Variable) String() string {
+func (v variableExpr) String() string {
 	return fmt.Sprintf("Variable(%s)", v.Name)
 }
 
-type Index struct {
-	Expr  Node
-	Index Node
+type indexExpr struct {
+	Expr  node
+	Index node
 	Line  int
 }
 
-func (i Index) String() string {
+func (i indexExpr) String() string {
 	return fmt.Sprintf("Index(%s, %s)", i.Expr.String(), i.Index.String())
 }
+
+// sliceExpr is xs:start..end. Start and End are nil when left out.
+type sliceExpr struct {
+	Expr  node
+	Start node
+	End   node
+	Line  int
+}
+
+func (s sliceExpr) String() string {
+	return fmt.Sprintf("Slice(%s, %v, %v)", s.Expr.String(), s.Start, s.End)
+}
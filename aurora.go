@@ -1,17 +1,196 @@
-package main
+package aurora
 
 import (
-	"bufio"
+	"context"
 	"fmt"
+	"io"
+	"math/rand"
 	"os"
+	"reflect"
+	"time"
 )
 
-func main() {
-	input := bufio.NewReader(os.Stdin)
-	lineBytes, _, _ := input.ReadLine()
-	line := string(lineBytes)
-	lexer := NewLexer(line)
-	parser := NewParser(lexer)
-	program := parser.statement()
-	fmt.Println(program)
+// DefaultMaxCallDepth is the call depth limit used when Options leaves
+// MaxCallDepth at zero.
+const DefaultMaxCallDepth = 10000
+
+// Program is a compiled script, ready to run on any number of VMs.
+type Program struct {
+	script   *AuroraFunction
+	warnings []string
+}
+
+func newProgram(chunk *chunk) *Program {
+	return &Program{script: &AuroraFunction{"[script]", []string{}, 0, chunk}}
+}
+
+// Warnings returns what the compiler found suspicious but not wrong enough
+// to reject, such as a match over literals without a default case. Programs
+// read from bytecode have none.
+func (p *Program) Warnings() []string {
+	return p.warnings
+}
+
+// Compile parses and compiles an Aurora script.
+func Compile(src string) (*Program, error) {
+	chunk, warnings, err := compileSource(src)
+	if err != nil {
+		return nil, err
+	}
+	program := newProgram(chunk)
+	program.warnings = warnings
+	return program, nil
+}
+
+// Options configures a VM created by NewVM.
+type Options struct {
+	// MaxCallDepth caps the number of active call frames, including the
+	// top-level script. Zero uses DefaultMaxCallDepth and a negative value
+	// removes the limit.
+	MaxCallDepth int
+
+	// MaxInstructions caps the instructions a single Run, RunContext or host
+	// Call may execute; exceeding it raises an error wrapping
+	// ErrInstructionLimit. Zero means no limit.
+	MaxInstructions int64
+
+	// MaxMemory caps the approximate bytes of strings, lists and maps a
+	// single run may allocate; exceeding it raises an error wrapping
+	// ErrMemoryLimit. Memory is counted as it is allocated, not as it is
+	// live, so long-running loops that build throwaway values use it up too.
+	// Zero means no limit.
+	MaxMemory int64
+
+	// FieldTag is the struct tag that maps Go field names to Aurora map keys
+	// when values are converted. Empty means DefaultFieldTag.
+	FieldTag string
+
+	// Stdout receives the output of print. Nil means os.Stdout.
+	Stdout io.Writer
+
+	// Random is the source for random, random_int, shuffle and choice. Pass
+	// a generator with a fixed seed for reproducible runs; nil seeds one from
+	// the clock.
+	Random *rand.Rand
+
+	// Capabilities decides which file, environment and argument functions
+	// scripts get. The zero value grants none of them.
+	Capabilities Capabilities
+
+	// Sandbox leaves out every built-in with ambient access to the host:
+	// print unless Stdout is set, the random functions unless Random is set,
+	// now, clock and sleep, and the Local time zone. Capabilities and any
+	// natives the host registers still apply, so a sandboxed script can only
+	// reach what it is explicitly handed. Given the same inputs, a sandboxed
+	// script always behaves the same.
+	Sandbox bool
+}
+
+// NewVM creates a VM whose only globals are the built-in functions.
+func NewVM(opts Options) *AuroraVM {
+	vm := &AuroraVM{
+		stack:           make([]Value, 0, 1024),
+		callStack:       []callFrame{},
+		globals:         map[string]Value{},
+		maxCallDepth:    opts.MaxCallDepth,
+		maxInstructions: opts.MaxInstructions,
+		maxMemory:       opts.MaxMemory,
+		converter:       converter{opts.FieldTag},
+		stdout:          opts.Stdout,
+		random:          opts.Random,
+		ctx:             context.Background(),
+		start:           time.Now(),
+		sandbox:         opts.Sandbox,
+	}
+	if vm.converter.tag == "" {
+		vm.converter.tag = DefaultFieldTag
+	}
+	if vm.stdout == nil && !vm.sandbox {
+		vm.stdout = os.Stdout
+	}
+	if vm.random == nil && !vm.sandbox {
+		vm.random = rand.New(rand.NewSource(time.Now().UnixNano()))
+	}
+	if vm.stdout != nil {
+		vm.Register("print", Variadic, builtinPrint)
+	}
+	if vm.random != nil {
+		vm.registerBuiltins(randomBuiltins)
+	}
+	if !vm.sandbox {
+		vm.registerBuiltins(clockBuiltins)
+	}
+	vm.registerBuiltins(coreBuiltins)
+	vm.registerBuiltins(stringBuiltins)
+	vm.registerBuiltins(mathBuiltins)
+	vm.registerBuiltins(jsonBuiltins)
+	vm.registerBuiltins(regexBuiltins)
+	vm.registerBuiltins(timeBuiltins)
+	vm.globals["math"] = mathConstants()
+	vm.registerIO(opts.Capabilities)
+	if vm.maxCallDepth == 0 {
+		vm.maxCallDepth = DefaultMaxCallDepth
+	} else if vm.maxCallDepth < 0 {
+		vm.maxCallDepth = 0
+	}
+	return vm
+}
+
+// Run executes the top level of program. Globals it defines stay on the VM,
+// so later runs and calls can use them.
+func (vm *AuroraVM) Run(program *Program) error {
+	return vm.RunContext(context.Background(), program)
+}
+
+// RunContext is like Run, but stops the script with an error wrapping
+// ctx.Err() once ctx is done. Functions that wait, such as sleep, are
+// interrupted too.
+func (vm *AuroraVM) RunContext(ctx context.Context, program *Program) error {
+	saved := vm.ctx
+	vm.ctx = ctx
+	defer func() { vm.ctx = saved }()
+	_, err := vm.execute(program.script, nil, typeProgram)
+	return err
+}
+
+// Call invokes the global function name with args converted as by ToValue.
+// Calling a type makes a record of it.
+func (vm *AuroraVM) Call(name string, args ...any) (Value, error) {
+	fn, ok := vm.globals[name]
+	if !ok {
+		return Nil, fmt.Errorf("undefined function '%s'", name)
+	}
+	if fn.kind != FunctionKind && fn.kind != NativeKind && fn.kind != TypeKind {
+		return Nil, fmt.Errorf("'%s' is a %s, not a function", name, fn.kind)
+	}
+	values := make([]Value, len(args))
+	for i, arg := range args {
+		value, err := vm.converter.toValue(reflect.ValueOf(arg))
+		if err != nil {
+			return Nil, fmt.Errorf("argument %d to %s: %w", i+1, name, err)
+		}
+		values[i] = value
+	}
+	return vm.CallValue(fn, values...)
+}
+
+// Lookup returns the value of the global name.
+func (vm *AuroraVM) Lookup(name string) (Value, bool) {
+	value, ok := vm.globals[name]
+	return value, ok
+}
+
+// Set defines the global name, converting value as by ToValue. A Go func
+// becomes a native function called name, which returns a list when the func
+// has several results besides a trailing error.
+func (vm *AuroraVM) Set(name string, value any) error {
+	v, err := vm.converter.toValue(reflect.ValueOf(value))
+	if err != nil {
+		return fmt.Errorf("%s: %w", name, err)
+	}
+	if reflect.ValueOf(value).Kind() == reflect.Func && v.kind == NativeKind {
+		v.AsNative().Name = name
+	}
+	vm.globals[name] = v
+	return nil
 }
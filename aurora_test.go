@@ -0,0 +1,54 @@
+package aurora
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+// run compiles and runs src on a new VM with opts, failing the test if src
+// does not compile.
+func run(t *testing.T, opts Options, src string) (*AuroraVM, error) {
+	t.Helper()
+	program, err := Compile(src)
+	if err != nil {
+		t.Fatalf("compile: %v", err)
+	}
+	vm := NewVM(opts)
+	return vm, vm.Run(program)
+}
+
+// scriptCase is a script and what it prints, or part of the error it fails
+// with.
+type scriptCase struct {
+	name, src, out string
+}
+
+// runScripts runs each case on a VM with opts and compares what it
+// prints.
+func runScripts(t *testing.T, opts Options, cases []scriptCase) {
+	t.Helper()
+	for _, tc := range cases {
+		var out bytes.Buffer
+		opts.Stdout = &out
+		if _, err := run(t, opts, tc.src); err != nil {
+			t.Errorf("%s: %v", tc.name, err)
+			continue
+		}
+		if out.String() != tc.out {
+			t.Errorf("%s: printed %q, want %q", tc.name, out.String(), tc.out)
+		}
+	}
+}
+
+// runScriptErrors runs each case on a VM with opts and checks that it fails
+// with an error containing out.
+func runScriptErrors(t *testing.T, opts Options, cases []scriptCase) {
+	t.Helper()
+	for _, tc := range cases {
+		_, err := run(t, opts, tc.src)
+		if err == nil || !strings.Contains(err.Error(), tc.out) {
+			t.Errorf("%s: got %v, want %s", tc.name, err, tc.out)
+		}
+	}
+}
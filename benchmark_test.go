@@ -0,0 +1,144 @@
+package aurora
+
+import "testing"
+
+func benchmarkScript(b *testing.B, src string) {
+	program, err := Compile(src)
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if err := NewVM(Options{}).Run(program); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// BenchmarkArithmeticLoop is the loop the tagged Value representation was
+// measured with.
+func BenchmarkArithmeticLoop(b *testing.B) {
+	benchmarkScript(b, `s = 0
+i = 0
+while i < 100000
+  s = s + i * 2 - 1
+  i = i + 1
+end
+`)
+}
+
+func BenchmarkFib(b *testing.B) {
+	benchmarkScript(b, `fn fib n
+  if n < 2
+    return n
+  end
+  return fib(n - 1) + fib(n - 2)
+end
+x = fib(25)
+`)
+}
+
+// BenchmarkRegistersBoxed and BenchmarkRegistersTagged compare the register
+// representation Value replaced with Value itself. Both run the register
+// traffic of BenchmarkArithmeticLoop as its instructions would: loading the
+// globals and constants, the arithmetic and the comparison, and storing the
+// results. In the boxed design a register points to an any, so every load
+// and every result allocates and every operation type-switches on the box.
+// Compare them with go test -bench Registers -benchmem.
+func BenchmarkRegistersBoxed(b *testing.B) {
+	b.ReportAllocs()
+	for n := 0; n < b.N; n++ {
+		var registers [4]*any
+		globals := map[string]any{"s": 0.0, "i": 0.0}
+		constants := []any{0.0, 100000.0, 2.0, 1.0}
+		load := func(register int, value any) {
+			registers[register] = &value
+		}
+		arithmetic := func(op byte, a, b, dest int) {
+			var result any
+			switch x := (*registers[a]).(type) {
+			case float64:
+				y := (*registers[b]).(float64)
+				switch op {
+				case '+':
+					result = x + y
+				case '-':
+					result = x - y
+				case '*':
+					result = x * y
+				case '<':
+					result = x < y
+				}
+			}
+			registers[dest] = &result
+		}
+		for {
+			load(0, globals["i"])
+			load(1, constants[1])
+			arithmetic('<', 0, 1, 0)
+			if !(*registers[0]).(bool) {
+				break
+			}
+			load(0, globals["s"])
+			load(1, globals["i"])
+			load(2, constants[2])
+			arithmetic('*', 1, 2, 1)
+			arithmetic('+', 0, 1, 0)
+			load(1, constants[3])
+			arithmetic('-', 0, 1, 0)
+			globals["s"] = *registers[0]
+			load(0, globals["i"])
+			load(1, constants[3])
+			arithmetic('+', 0, 1, 0)
+			globals["i"] = *registers[0]
+		}
+	}
+}
+
+func BenchmarkRegistersTagged(b *testing.B) {
+	b.ReportAllocs()
+	for n := 0; n < b.N; n++ {
+		var registers [4]Value
+		globals := map[string]Value{"s": NumberValue(0), "i": NumberValue(0)}
+		constants := []Value{NumberValue(0), NumberValue(100000), NumberValue(2), NumberValue(1)}
+		load := func(register int, value Value) {
+			registers[register] = value
+		}
+		arithmetic := func(op byte, a, b, dest int) {
+			x, y := registers[a], registers[b]
+			if x.kind == NumberKind && y.kind == NumberKind {
+				switch op {
+				case '+':
+					registers[dest] = NumberValue(x.number + y.number)
+				case '-':
+					registers[dest] = NumberValue(x.number - y.number)
+				case '*':
+					registers[dest] = NumberValue(x.number * y.number)
+				case '<':
+					registers[dest] = BoolValue(x.number < y.number)
+				}
+			}
+		}
+		for {
+			load(0, globals["i"])
+			load(1, constants[1])
+			arithmetic('<', 0, 1, 0)
+			if !registers[0].Truthy() {
+				break
+			}
+			load(0, globals["s"])
+			load(1, globals["i"])
+			load(2, constants[2])
+			arithmetic('*', 1, 2, 1)
+			arithmetic('+', 0, 1, 0)
+			load(1, constants[3])
+			arithmetic('-', 0, 1, 0)
+			globals["s"] = registers[0]
+			load(0, globals["i"])
+			load(1, constants[3])
+			arithmetic('+', 0, 1, 0)
+			globals["i"] = registers[0]
+		}
+	}
+}
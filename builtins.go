@@ -0,0 +1,447 @@
+package aurora
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+type builtin struct {
+	name  string
+	arity int
+	fn    NativeFunc
+}
+
+func (vm *AuroraVM) registerBuiltins(builtins []builtin) {
+	for _, b := range builtins {
+		vm.Register(b.name, b.arity, b.fn)
+	}
+}
+
+var coreBuiltins = []builtin{
+	{"len", 1, builtinLen},
+	{"type", 1, builtinType},
+	{"str", 1, builtinStr},
+	{"num", 1, builtinNum},
+	{"int", 1, builtinInt},
+	{"push", Variadic, builtinPush},
+	{"pop", 1, builtinPop},
+	{"insert", 3, builtinInsert},
+	{"remove", 2, builtinRemove},
+	{"slice", Variadic, builtinSlice},
+	{"contains", 2, builtinContains},
+	{"sort", Variadic, builtinSort},
+	{"reverse", 1, builtinReverse},
+	{"min", Variadic, builtinMin},
+	{"max", Variadic, builtinMax},
+	{"abs", 1, mathFunc(math.Abs)},
+	{"floor", 1, mathFunc(math.Floor)},
+	{"ceil", 1, mathFunc(math.Ceil)},
+	{"round", 1, mathFunc(math.Round)},
+	{"sqrt", 1, mathFunc(math.Sqrt)},
+	{"keys", 1, builtinKeys},
+	{"values", 1, builtinValues},
+	{"error", Variadic, builtinError},
+}
+
+// Argument helpers shared by the built-in modules. Their errors are turned
+// into runtime errors prefixed with the function's name.
+
+func argError(i int, want string, got Value) error {
+	return fmt.Errorf("argument %d must be %s, not %s", i+1, want, got.kind)
+}
+
+func checkArgCount(args []Value, min, max int) error {
+	if len(args) < min || len(args) > max {
+		if min == max {
+			return fmt.Errorf("expects %d arguments, got %d", min, len(args))
+		}
+		return fmt.Errorf("expects %d to %d arguments, got %d", min, max, len(args))
+	}
+	return nil
+}
+
+func numberArg(args []Value, i int) (float64, error) {
+	if args[i].kind != NumberKind {
+		return 0, argError(i, "a number", args[i])
+	}
+	return args[i].number, nil
+}
+
+func intArg(args []Value, i int) (int, error) {
+	n, err := numberArg(args, i)
+	if err != nil {
+		return 0, err
+	}
+	if n != math.Trunc(n) || math.Abs(n) > 1<<53 {
+		return 0, fmt.Errorf("argument %d must be an integer, not %s", i+1, args[i])
+	}
+	return int(n), nil
+}
+
+func stringArg(args []Value, i int) (string, error) {
+	if args[i].kind != StringKind {
+		return "", argError(i, "a string", args[i])
+	}
+	return args[i].AsString(), nil
+}
+
+func listArg(args []Value, i int) (*ListObject, error) {
+	if args[i].kind != ListKind {
+		return nil, argError(i, "a list", args[i])
+	}
+	return args[i].AsList(), nil
+}
+
+func mathFunc(fn func(float64) float64) NativeFunc {
+	return func(vm *AuroraVM, args []Value) (Value, error) {
+		n, err := numberArg(args, 0)
+		if err != nil {
+			return Nil, err
+		}
+		return NumberValue(fn(n)), nil
+	}
+}
+
+func builtinPrint(vm *AuroraVM, args []Value) (Value, error) {
+	parts := make([]string, len(args))
+	for i, arg := range args {
+		parts[i] = arg.String()
+	}
+	_, err := fmt.Fprintln(vm.stdout, strings.Join(parts, " "))
+	return Nil, err
+}
+
+func builtinLen(vm *AuroraVM, args []Value) (Value, error) {
+	switch args[0].kind {
+	case StringKind:
+		return NumberValue(float64(utf8.RuneCountInString(args[0].AsString()))), nil
+	case ListKind:
+		return NumberValue(float64(len(args[0].AsList().items))), nil
+	case MapKind:
+		return NumberValue(float64(len(args[0].AsMap().items))), nil
+	}
+	return Nil, argError(0, "a string, list or map", args[0])
+}
+
+func builtinType(vm *AuroraVM, args []Value) (Value, error) {
+	if args[0].kind == NativeKind {
+		return StringValue(FunctionKind.String()), nil
+	}
+	if args[0].kind == RecordKind {
+		return StringValue(args[0].AsRecord().typ.name), nil
+	}
+	return StringValue(args[0].kind.String()), nil
+}
+
+func builtinStr(vm *AuroraVM, args []Value) (Value, error) {
+	return StringValue(args[0].String()), nil
+}
+
+func toNumber(v Value) (float64, error) {
+	switch v.kind {
+	case NumberKind:
+		return v.number, nil
+	case BoolKind:
+		return v.number, nil
+	case StringKind:
+		n, err := strconv.ParseFloat(strings.TrimSpace(v.AsString()), 64)
+		if err != nil {
+			return 0, fmt.Errorf("cannot convert %q to a number", v.AsString())
+		}
+		return n, nil
+	}
+	return 0, fmt.Errorf("cannot convert %s to a number", v.kind)
+}
+
+func builtinNum(vm *AuroraVM, args []Value) (Value, error) {
+	n, err := toNumber(args[0])
+	if err != nil {
+		return Nil, err
+	}
+	return NumberValue(n), nil
+}
+
+func builtinInt(vm *AuroraVM, args []Value) (Value, error) {
+	n, err := toNumber(args[0])
+	if err != nil {
+		return Nil, err
+	}
+	return NumberValue(math.Trunc(n)), nil
+}
+
+func builtinPush(vm *AuroraVM, args []Value) (Value, error) {
+	if len(args) < 2 {
+		return Nil, fmt.Errorf("expects at least 2 arguments, got %d", len(args))
+	}
+	list, err := listArg(args, 0)
+	if err != nil {
+		return Nil, err
+	}
+	vm.allocate(int64(len(args)-1) * valueSize)
+	list.items = append(list.items, args[1:]...)
+	return args[0], nil
+}
+
+func builtinPop(vm *AuroraVM, args []Value) (Value, error) {
+	list, err := listArg(args, 0)
+	if err != nil {
+		return Nil, err
+	}
+	if len(list.items) == 0 {
+		return Nil, errors.New("pop from empty list")
+	}
+	last := list.items[len(list.items)-1]
+	list.items = list.items[:len(list.items)-1]
+	return last, nil
+}
+
+func builtinInsert(vm *AuroraVM, args []Value) (Value, error) {
+	list, err := listArg(args, 0)
+	if err != nil {
+		return Nil, err
+	}
+	index, err := intArg(args, 1)
+	if err != nil {
+		return Nil, err
+	}
+	if index < 0 || index > len(list.items) {
+		return Nil, fmt.Errorf("index %d out of range for list of length %d", index, len(list.items))
+	}
+	vm.allocate(valueSize)
+	list.items = append(list.items, Nil)
+	copy(list.items[index+1:], list.items[index:])
+	list.items[index] = args[2]
+	return args[0], nil
+}
+
+// builtinRemove deletes an item from a list by index or from a map by key and
+// returns it.
+func builtinRemove(vm *AuroraVM, args []Value) (Value, error) {
+	switch args[0].kind {
+	case ListKind:
+		list := args[0].AsList()
+		index, err := intArg(args, 1)
+		if err != nil {
+			return Nil, err
+		}
+		if index < 0 || index >= len(list.items) {
+			return Nil, fmt.Errorf("index %d out of range for list of length %d", index, len(list.items))
+		}
+		item := list.items[index]
+		list.items = append(list.items[:index], list.items[index+1:]...)
+		return item, nil
+	case MapKind:
+		m := args[0].AsMap()
+		key := args[1].String()
+		item := m.items[key]
+		delete(m.items, key)
+		return item, nil
+	}
+	return Nil, argError(0, "a list or map", args[0])
+}
+
+func mapArg(args []Value, i int) (*MapObject, error) {
+	if args[i].kind != MapKind {
+		return nil, argError(i, "a map", args[i])
+	}
+	return args[i].AsMap(), nil
+}
+
+// builtinKeys returns the keys of a map in sorted order, the order for loops
+// visit them in.
+func builtinKeys(vm *AuroraVM, args []Value) (Value, error) {
+	m, err := mapArg(args, 0)
+	if err != nil {
+		return Nil, err
+	}
+	return stringList(m.sortedKeys()), nil
+}
+
+// builtinValues returns the values of a map, ordered by their keys.
+func builtinValues(vm *AuroraVM, args []Value) (Value, error) {
+	m, err := mapArg(args, 0)
+	if err != nil {
+		return Nil, err
+	}
+	keys := m.sortedKeys()
+	items := make([]Value, len(keys))
+	for i, key := range keys {
+		items[i] = m.items[key]
+	}
+	return ListValue(items), nil
+}
+
+// builtinError makes an error value to throw, with kind "error" unless
+// another is given.
+func builtinError(vm *AuroraVM, args []Value) (Value, error) {
+	if err := checkArgCount(args, 1, 2); err != nil {
+		return Nil, err
+	}
+	message, err := stringArg(args, 0)
+	if err != nil {
+		return Nil, err
+	}
+	kind := KindError
+	if len(args) == 2 {
+		if kind, err = stringArg(args, 1); err != nil {
+			return Nil, err
+		}
+	}
+	return ErrorValue(&ErrorObject{message, kind, vm.currentLine(), vm.backtrace(), Nil}), nil
+}
+
+// builtinSlice returns the items or characters from start up to, but not
+// including, end, which defaults to the length.
+func builtinSlice(vm *AuroraVM, args []Value) (Value, error) {
+	if err := checkArgCount(args, 2, 3); err != nil {
+		return Nil, err
+	}
+	var length int
+	switch args[0].kind {
+	case ListKind:
+		length = len(args[0].AsList().items)
+	case StringKind:
+		length = utf8.RuneCountInString(args[0].AsString())
+	default:
+		return Nil, argError(0, "a list or string", args[0])
+	}
+	start, err := intArg(args, 1)
+	if err != nil {
+		return Nil, err
+	}
+	end := length
+	if len(args) == 3 {
+		if end, err = intArg(args, 2); err != nil {
+			return Nil, err
+		}
+	}
+	if start < 0 || end > length || start > end {
+		return Nil, fmt.Errorf("range %d..%d out of bounds for length %d", start, end, length)
+	}
+	if args[0].kind == StringKind {
+		runes := []rune(args[0].AsString())
+		return StringValue(string(runes[start:end])), nil
+	}
+	items := make([]Value, end-start)
+	copy(items, args[0].AsList().items[start:end])
+	return ListValue(items), nil
+}
+
+func builtinContains(vm *AuroraVM, args []Value) (Value, error) {
+	switch args[0].kind {
+	case ListKind:
+		for _, item := range args[0].AsList().items {
+			if valuesEqual(item, args[1]) {
+				return BoolValue(true), nil
+			}
+		}
+		return BoolValue(false), nil
+	case StringKind:
+		sub, err := stringArg(args, 1)
+		if err != nil {
+			return Nil, err
+		}
+		return BoolValue(strings.Contains(args[0].AsString(), sub)), nil
+	case MapKind:
+		_, ok := args[0].AsMap().items[args[1].String()]
+		return BoolValue(ok), nil
+	}
+	return Nil, argError(0, "a list, string or map", args[0])
+}
+
+// builtinSort sorts a list in place, either in natural order or by a
+// function returning whether its first argument sorts before its second.
+func builtinSort(vm *AuroraVM, args []Value) (Value, error) {
+	if err := checkArgCount(args, 1, 2); err != nil {
+		return Nil, err
+	}
+	list, err := listArg(args, 0)
+	if err != nil {
+		return Nil, err
+	}
+	// sort a copy, so a comparator that changes the list cannot make the
+	// sort index out of range
+	items := append([]Value(nil), list.items...)
+	var sortErr error
+	less := func(i, j int) bool {
+		cmp, ok := compareValues(items[i], items[j])
+		if !ok && sortErr == nil {
+			sortErr = fmt.Errorf("cannot compare %s with %s", items[i].kind, items[j].kind)
+		}
+		return cmp < 0
+	}
+	if len(args) == 2 {
+		less = func(i, j int) bool {
+			if sortErr != nil {
+				return false
+			}
+			result, err := vm.CallValue(args[1], items[i], items[j])
+			if err != nil {
+				sortErr = err
+				return false
+			}
+			return result.Truthy()
+		}
+	}
+	sort.SliceStable(items, less)
+	if sortErr != nil {
+		return Nil, sortErr
+	}
+	list.items = items
+	return args[0], nil
+}
+
+// builtinReverse reverses a list in place, or returns a reversed copy of a
+// string.
+func builtinReverse(vm *AuroraVM, args []Value) (Value, error) {
+	switch args[0].kind {
+	case ListKind:
+		items := args[0].AsList().items
+		for i, j := 0, len(items)-1; i < j; i, j = i+1, j-1 {
+			items[i], items[j] = items[j], items[i]
+		}
+		return args[0], nil
+	case StringKind:
+		runes := []rune(args[0].AsString())
+		for i, j := 0, len(runes)-1; i < j; i, j = i+1, j-1 {
+			runes[i], runes[j] = runes[j], runes[i]
+		}
+		return StringValue(string(runes)), nil
+	}
+	return Nil, argError(0, "a list or string", args[0])
+}
+
+// extreme implements min and max, which take either several arguments or a
+// single list.
+func extreme(args []Value, want int) (Value, error) {
+	if len(args) == 1 && args[0].kind == ListKind {
+		args = args[0].AsList().items
+	}
+	if len(args) == 0 {
+		return Nil, errors.New("no values to compare")
+	}
+	best := args[0]
+	for _, arg := range args[1:] {
+		cmp, ok := compareValues(arg, best)
+		if !ok {
+			return Nil, fmt.Errorf("cannot compare %s with %s", arg.kind, best.kind)
+		}
+		if cmp == want {
+			best = arg
+		}
+	}
+	return best, nil
+}
+
+func builtinMin(vm *AuroraVM, args []Value) (Value, error) {
+	return extreme(args, -1)
+}
+
+func builtinMax(vm *AuroraVM, args []Value) (Value, error) {
+	return extreme(args, 1)
+}
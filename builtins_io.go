@@ -0,0 +1,227 @@
+package aurora
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// ErrAccessDenied is wrapped by the errors I/O functions raise when a script
+// reaches outside what its Capabilities allow.
+var ErrAccessDenied = errors.New("access denied")
+
+// Capabilities grants scripts access to the host system. The zero value
+// grants nothing, and functions for an ungranted capability are not defined
+// at all.
+type Capabilities struct {
+	// ReadPaths are the files and directory trees that read_file, list_dir
+	// and exists may look at. Anything under WritePaths is readable too.
+	ReadPaths []string
+
+	// WritePaths are the files and directory trees that write_file,
+	// append_file, make_dir and delete_file may change.
+	WritePaths []string
+
+	// Env lists the environment variables that env may read.
+	Env []string
+
+	// Args, when non-nil, is the list returned by args.
+	Args []string
+}
+
+// sandboxFS holds the resolved roots a VM checks file access against.
+type sandboxFS struct {
+	read  []string
+	write []string
+}
+
+var readBuiltins = []builtin{
+	{"read_file", 1, builtinReadFile},
+	{"list_dir", 1, builtinListDir},
+	{"exists", 1, builtinExists},
+}
+
+var writeBuiltins = []builtin{
+	{"write_file", 2, builtinWriteFile},
+	{"append_file", 2, builtinAppendFile},
+	{"make_dir", 1, builtinMakeDir},
+	{"delete_file", 1, builtinDeleteFile},
+}
+
+// registerIO defines the functions caps grants. Roots that cannot be
+// resolved grant nothing.
+func (vm *AuroraVM) registerIO(caps Capabilities) {
+	for _, path := range caps.WritePaths {
+		if root, err := resolvePath(path); err == nil {
+			vm.fs.write = append(vm.fs.write, root)
+		}
+	}
+	vm.fs.read = append(vm.fs.read, vm.fs.write...)
+	for _, path := range caps.ReadPaths {
+		if root, err := resolvePath(path); err == nil {
+			vm.fs.read = append(vm.fs.read, root)
+		}
+	}
+	if len(vm.fs.read) > 0 {
+		vm.registerBuiltins(readBuiltins)
+	}
+	if len(vm.fs.write) > 0 {
+		vm.registerBuiltins(writeBuiltins)
+	}
+	if len(caps.Env) > 0 {
+		allowed := map[string]bool{}
+		for _, name := range caps.Env {
+			allowed[name] = true
+		}
+		vm.Register("env", 1, func(vm *AuroraVM, args []Value) (Value, error) {
+			name, err := stringArg(args, 0)
+			if err != nil {
+				return Nil, err
+			}
+			if !allowed[name] {
+				return Nil, fmt.Errorf("environment variable %s: %w", name, ErrAccessDenied)
+			}
+			if value, ok := os.LookupEnv(name); ok {
+				return StringValue(value), nil
+			}
+			return Nil, nil
+		})
+	}
+	if caps.Args != nil {
+		scriptArgs := append([]string(nil), caps.Args...)
+		vm.Register("args", 0, func(vm *AuroraVM, args []Value) (Value, error) {
+			return stringList(scriptArgs), nil
+		})
+	}
+}
+
+// resolvePath makes path absolute and resolves symlinks in the longest
+// prefix of it that exists, so a link cannot smuggle access outside a root.
+func resolvePath(path string) (string, error) {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return "", err
+	}
+	dir, rest := abs, ""
+	for {
+		if real, err := filepath.EvalSymlinks(dir); err == nil {
+			return filepath.Join(real, rest), nil
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return abs, nil
+		}
+		rest = filepath.Join(filepath.Base(dir), rest)
+		dir = parent
+	}
+}
+
+func within(path, root string) bool {
+	rel, err := filepath.Rel(root, path)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+// checkPath resolves the path argument at i and checks it lies under one of
+// roots.
+func checkPath(args []Value, i int, roots []string) (string, error) {
+	path, err := stringArg(args, i)
+	if err != nil {
+		return "", err
+	}
+	resolved, err := resolvePath(path)
+	if err != nil {
+		return "", err
+	}
+	for _, root := range roots {
+		if within(resolved, root) {
+			return resolved, nil
+		}
+	}
+	return "", fmt.Errorf("%s: %w", path, ErrAccessDenied)
+}
+
+func builtinReadFile(vm *AuroraVM, args []Value) (Value, error) {
+	path, err := checkPath(args, 0, vm.fs.read)
+	if err != nil {
+		return Nil, err
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return Nil, err
+	}
+	return StringValue(string(data)), nil
+}
+
+// builtinListDir returns the sorted names of the entries in a directory.
+func builtinListDir(vm *AuroraVM, args []Value) (Value, error) {
+	path, err := checkPath(args, 0, vm.fs.read)
+	if err != nil {
+		return Nil, err
+	}
+	entries, err := os.ReadDir(path)
+	if err != nil {
+		return Nil, err
+	}
+	names := make([]string, len(entries))
+	for i, entry := range entries {
+		names[i] = entry.Name()
+	}
+	sort.Strings(names)
+	return stringList(names), nil
+}
+
+func builtinExists(vm *AuroraVM, args []Value) (Value, error) {
+	path, err := checkPath(args, 0, vm.fs.read)
+	if err != nil {
+		return Nil, err
+	}
+	_, err = os.Stat(path)
+	return BoolValue(err == nil), nil
+}
+
+func writeFile(vm *AuroraVM, args []Value, flag int) (Value, error) {
+	path, err := checkPath(args, 0, vm.fs.write)
+	if err != nil {
+		return Nil, err
+	}
+	content, err := stringArg(args, 1)
+	if err != nil {
+		return Nil, err
+	}
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|flag, 0644)
+	if err != nil {
+		return Nil, err
+	}
+	if _, err := f.WriteString(content); err != nil {
+		f.Close()
+		return Nil, err
+	}
+	return Nil, f.Close()
+}
+
+func builtinWriteFile(vm *AuroraVM, args []Value) (Value, error) {
+	return writeFile(vm, args, os.O_TRUNC)
+}
+
+func builtinAppendFile(vm *AuroraVM, args []Value) (Value, error) {
+	return writeFile(vm, args, os.O_APPEND)
+}
+
+func builtinMakeDir(vm *AuroraVM, args []Value) (Value, error) {
+	path, err := checkPath(args, 0, vm.fs.write)
+	if err != nil {
+		return Nil, err
+	}
+	return Nil, os.MkdirAll(path, 0755)
+}
+
+func builtinDeleteFile(vm *AuroraVM, args []Value) (Value, error) {
+	path, err := checkPath(args, 0, vm.fs.write)
+	if err != nil {
+		return Nil, err
+	}
+	return Nil, os.Remove(path)
+}
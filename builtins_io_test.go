@@ -0,0 +1,143 @@
+package aurora
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// runIO runs src on a VM granted caps, with root defined as a global.
+func runIO(t *testing.T, caps Capabilities, root, src string) (*AuroraVM, error) {
+	t.Helper()
+	program, err := Compile(src)
+	if err != nil {
+		t.Fatalf("compile: %v", err)
+	}
+	vm := NewVM(Options{Capabilities: caps})
+	vm.Set("root", root)
+	return vm, vm.Run(program)
+}
+
+func TestIOFunctionsNeedACapability(t *testing.T) {
+	vm := NewVM(Options{})
+	for _, name := range []string{"read_file", "list_dir", "exists", "write_file", "append_file", "make_dir", "delete_file", "env", "args"} {
+		if _, ok := vm.Lookup(name); ok {
+			t.Errorf("%s is defined without a capability", name)
+		}
+	}
+	vm = NewVM(Options{Capabilities: Capabilities{ReadPaths: []string{t.TempDir()}}})
+	if _, ok := vm.Lookup("read_file"); !ok {
+		t.Error("read_file is missing although reading was granted")
+	}
+	if _, ok := vm.Lookup("write_file"); ok {
+		t.Error("write_file is defined although only reading was granted")
+	}
+}
+
+func TestIOReadsAndWritesUnderRoots(t *testing.T) {
+	dir := t.TempDir()
+	vm, err := runIO(t, Capabilities{WritePaths: []string{dir}}, dir, `path = root + "/sub/notes.txt"
+x = make_dir(root + "/sub")
+x = write_file(path, "one")
+x = append_file(path, " two")
+text = read_file(path)
+names = list_dir(root + "/sub")
+there = exists(path)
+x = delete_file(path)
+left = exists(path)
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var text string
+	var names []string
+	var there, left bool
+	vm.Get("text", &text)
+	vm.Get("names", &names)
+	vm.Get("there", &there)
+	vm.Get("left", &left)
+	if text != "one two" || len(names) != 1 || names[0] != "notes.txt" || !there || left {
+		t.Errorf("got %q, %v, %v, %v", text, names, there, left)
+	}
+}
+
+func TestIODeniesPathsOutsideRoots(t *testing.T) {
+	parent := t.TempDir()
+	dir := filepath.Join(parent, "allowed")
+	secret := filepath.Join(parent, "secret.txt")
+	if err := os.Mkdir(dir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(secret, []byte("secret"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(secret, filepath.Join(dir, "link.txt")); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(parent, filepath.Join(dir, "up")); err != nil {
+		t.Fatal(err)
+	}
+	caps := Capabilities{ReadPaths: []string{dir}}
+	for _, src := range []string{
+		`x = read_file("` + secret + `")`,
+		`x = read_file(root + "/../secret.txt")`,
+		`x = read_file(root + "/sub/../../secret.txt")`,
+		`x = read_file(root + "/link.txt")`,
+		`x = read_file(root + "/up/secret.txt")`,
+		`x = list_dir(root + "/..")`,
+		`x = exists(root + "/up/missing.txt")`,
+	} {
+		_, err := runIO(t, caps, dir, src)
+		if !errors.Is(err, ErrAccessDenied) {
+			t.Errorf("%s: got %v, want ErrAccessDenied", src, err)
+		}
+	}
+}
+
+func TestIOWriteDeniedOutsideWriteRoots(t *testing.T) {
+	readDir, writeDir := t.TempDir(), t.TempDir()
+	caps := Capabilities{ReadPaths: []string{readDir}, WritePaths: []string{writeDir}}
+	_, err := runIO(t, caps, readDir, `x = write_file(root + "/f.txt", "x")`)
+	if !errors.Is(err, ErrAccessDenied) {
+		t.Errorf("writing to a read root: got %v, want ErrAccessDenied", err)
+	}
+	_, err = runIO(t, caps, writeDir, `x = write_file(root + "/f.txt", "x")
+y = read_file(root + "/f.txt")`)
+	if err != nil {
+		t.Errorf("write roots should be readable: %v", err)
+	}
+}
+
+func TestEnvAllowlist(t *testing.T) {
+	t.Setenv("AURORA_ALLOWED", "yes")
+	t.Setenv("AURORA_SECRET", "no")
+	caps := Capabilities{Env: []string{"AURORA_ALLOWED", "AURORA_UNSET"}}
+	vm, err := runIO(t, caps, "", `x = env("AURORA_ALLOWED")
+unset = type(env("AURORA_UNSET"))
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var x, unset string
+	vm.Get("x", &x)
+	vm.Get("unset", &unset)
+	if x != "yes" || unset != "nil" {
+		t.Errorf("env gave %q and %q, want yes and nil", x, unset)
+	}
+	_, err = runIO(t, caps, "", `x = env("AURORA_SECRET")`)
+	if !errors.Is(err, ErrAccessDenied) {
+		t.Errorf("got %v, want ErrAccessDenied", err)
+	}
+}
+
+func TestArgsCapability(t *testing.T) {
+	vm, err := runIO(t, Capabilities{Args: []string{"a", "b"}}, "", `x = args()`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var x []string
+	if vm.Get("x", &x); len(x) != 2 || x[0] != "a" || x[1] != "b" {
+		t.Errorf("args() = %v", x)
+	}
+}
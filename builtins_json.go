@@ -0,0 +1,237 @@
+package aurora
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// JSON null is decoded to nil, and nil is encoded as null.
+var jsonBuiltins = []builtin{
+	{"json_parse", 1, builtinJSONParse},
+	{"json_stringify", Variadic, builtinJSONStringify},
+}
+
+func builtinJSONParse(vm *AuroraVM, args []Value) (Value, error) {
+	s, err := stringArg(args, 0)
+	if err != nil {
+		return Nil, err
+	}
+	var data any
+	if err := json.Unmarshal([]byte(s), &data); err != nil {
+		var syntax *json.SyntaxError
+		if errors.As(err, &syntax) {
+			return Nil, fmt.Errorf("invalid JSON at byte %d: %s", syntax.Offset, syntax)
+		}
+		return Nil, err
+	}
+	return fromJSON(data), nil
+}
+
+func fromJSON(data any) Value {
+	switch data := data.(type) {
+	case bool:
+		return BoolValue(data)
+	case float64:
+		return NumberValue(data)
+	case string:
+		return StringValue(data)
+	case []any:
+		items := make([]Value, len(data))
+		for i, item := range data {
+			items[i] = fromJSON(item)
+		}
+		return ListValue(items)
+	case map[string]any:
+		items := make(map[string]Value, len(data))
+		for key, item := range data {
+			items[key] = fromJSON(item)
+		}
+		return MapValue(items)
+	}
+	return Nil
+}
+
+// builtinJSONStringify encodes a value as JSON. The optional indent is a
+// number of spaces or a string to indent nested values with; without it, or
+// with 0 or "", the output is compact. Map keys are written in sorted order so
+// the output is deterministic, unless a third argument of false asks for them
+// in whatever order is quickest. Sandboxed VMs always sort them.
+func builtinJSONStringify(vm *AuroraVM, args []Value) (Value, error) {
+	if err := checkArgCount(args, 1, 3); err != nil {
+		return Nil, err
+	}
+	e := jsonEncoder{vm: vm, seen: map[any]bool{}, sortKeys: true}
+	if len(args) == 3 {
+		if args[2].kind != BoolKind {
+			return Nil, argError(2, "a bool", args[2])
+		}
+		e.sortKeys = args[2].AsBool() || vm.sandbox
+	}
+	if len(args) >= 2 {
+		switch args[1].kind {
+		case NumberKind:
+			n, err := intArg(args, 1)
+			if err != nil {
+				return Nil, err
+			}
+			if n < 0 {
+				return Nil, errors.New("indent must not be negative")
+			}
+			vm.allocate(int64(n))
+			e.indent = strings.Repeat(" ", n)
+		case StringKind:
+			e.indent = args[1].AsString()
+		default:
+			return Nil, argError(1, "a number or string", args[1])
+		}
+	}
+	if err := e.encode(args[0], 0); err != nil {
+		return Nil, err
+	}
+	e.charge()
+	return StringValue(e.sb.String()), nil
+}
+
+type jsonEncoder struct {
+	vm     *AuroraVM
+	sb     strings.Builder
+	indent string
+	// sortKeys writes map keys in sorted order.
+	sortKeys bool
+	// seen holds the lists and maps being encoded, to reject cycles.
+	seen map[any]bool
+	// charged is how much of the output has been charged to the VM.
+	charged int
+}
+
+// charge charges the output written since the last call against the memory
+// limit. A list that holds the same list many times over encodes to far
+// more than it takes up, so the output is paid for as it grows.
+func (e *jsonEncoder) charge() {
+	e.vm.allocate(int64(e.sb.Len() - e.charged))
+	e.charged = e.sb.Len()
+}
+
+func (e *jsonEncoder) newline(depth int) {
+	if e.indent == "" {
+		return
+	}
+	e.sb.WriteByte('\n')
+	for i := 0; i < depth; i++ {
+		e.sb.WriteString(e.indent)
+	}
+}
+
+func (e *jsonEncoder) encode(v Value, depth int) error {
+	e.charge()
+	switch v.kind {
+	case NilKind:
+		e.sb.WriteString("null")
+	case BoolKind:
+		e.sb.WriteString(strconv.FormatBool(v.AsBool()))
+	case NumberKind:
+		n := v.number
+		if math.IsNaN(n) || math.IsInf(n, 0) {
+			return fmt.Errorf("cannot encode %s as JSON", v)
+		}
+		format := byte('f')
+		if abs := math.Abs(n); abs != 0 && (abs < 1e-6 || abs >= 1e21) {
+			format = 'e'
+		}
+		e.sb.WriteString(strconv.FormatFloat(n, format, -1, 64))
+	case StringKind:
+		writeJSONString(&e.sb, v.AsString())
+	case ListKind:
+		list := v.AsList()
+		if e.seen[list] {
+			return errors.New("cannot encode a list that contains itself")
+		}
+		e.seen[list] = true
+		defer delete(e.seen, list)
+		e.sb.WriteByte('[')
+		for i, item := range list.items {
+			if i > 0 {
+				e.sb.WriteByte(',')
+			}
+			e.newline(depth + 1)
+			if err := e.encode(item, depth+1); err != nil {
+				return err
+			}
+		}
+		if len(list.items) > 0 {
+			e.newline(depth)
+		}
+		e.sb.WriteByte(']')
+	case MapKind:
+		m := v.AsMap()
+		if e.seen[m] {
+			return errors.New("cannot encode a map that contains itself")
+		}
+		e.seen[m] = true
+		defer delete(e.seen, m)
+		var keys []string
+		if e.sortKeys {
+			keys = m.sortedKeys()
+		} else {
+			keys = make([]string, 0, len(m.items))
+			for key := range m.items {
+				keys = append(keys, key)
+			}
+		}
+		e.sb.WriteByte('{')
+		for i, key := range keys {
+			if i > 0 {
+				e.sb.WriteByte(',')
+			}
+			e.newline(depth + 1)
+			writeJSONString(&e.sb, key)
+			e.sb.WriteByte(':')
+			if e.indent != "" {
+				e.sb.WriteByte(' ')
+			}
+			if err := e.encode(m.items[key], depth+1); err != nil {
+				return err
+			}
+		}
+		if len(m.items) > 0 {
+			e.newline(depth)
+		}
+		e.sb.WriteByte('}')
+	default:
+		kind := v.kind
+		if kind == NativeKind {
+			kind = FunctionKind
+		}
+		return fmt.Errorf("cannot encode a %s as JSON", kind)
+	}
+	return nil
+}
+
+func writeJSONString(sb *strings.Builder, s string) {
+	const hex = "0123456789abcdef"
+	sb.WriteByte('"')
+	for _, r := range s {
+		switch {
+		case r == '"' || r == '\\':
+			sb.WriteByte('\\')
+			sb.WriteRune(r)
+		case r == '\n':
+			sb.WriteString(`\n`)
+		case r == '\r':
+			sb.WriteString(`\r`)
+		case r == '\t':
+			sb.WriteString(`\t`)
+		case r < 0x20:
+			sb.WriteString(`\u00`)
+			sb.WriteByte(hex[r>>4])
+			sb.WriteByte(hex[r&0xf])
+		default:
+			sb.WriteRune(r)
+		}
+	}
+	sb.WriteByte('"')
+}
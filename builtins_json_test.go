@@ -0,0 +1,109 @@
+package aurora
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+// runJSON runs src with doc defined as a global, since script strings cannot
+// hold quotes, and returns what it prints.
+func runJSON(t *testing.T, opts Options, doc, src string) (string, error) {
+	t.Helper()
+	program, err := Compile(src)
+	if err != nil {
+		t.Fatalf("compile: %v", err)
+	}
+	var out bytes.Buffer
+	opts.Stdout = &out
+	vm := NewVM(opts)
+	vm.Set("doc", doc)
+	err = vm.Run(program)
+	return out.String(), err
+}
+
+func TestJSONRoundTrip(t *testing.T) {
+	out, err := runJSON(t, Options{}, `{"b": [1, 2.5, true, null, "x\"y"], "a": {"c": -0.0000001}}`, `v = json_parse(doc)
+print type(v), type(v.b:3), v.b:4
+print json_stringify(v)
+print json_stringify(v, 2)
+print json_stringify(v.b, "> ")
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `map nil x"y
+{"a":{"c":-1e-07},"b":[1,2.5,true,null,"x\"y"]}
+{
+  "a": {
+    "c": -1e-07
+  },
+  "b": [
+    1,
+    2.5,
+    true,
+    null,
+    "x\"y"
+  ]
+}
+[
+> 1,
+> 2.5,
+> true,
+> null,
+> "x\"y"
+]
+`
+	if out != want {
+		t.Errorf("printed\n%s\nwant\n%s", out, want)
+	}
+}
+
+func TestJSONNullIsNotAGlobal(t *testing.T) {
+	if _, ok := NewVM(Options{}).Lookup("null"); ok {
+		t.Error("null is defined as a global")
+	}
+}
+
+func TestJSONSortKeysOption(t *testing.T) {
+	const doc = `{"d": 4, "b": 2, "a": 1, "c": 3, "e": {"z": 1, "y": 2}}`
+	const sorted = `{"a":1,"b":2,"c":3,"d":4,"e":{"y":2,"z":1}}` + "\n"
+	out, err := runJSON(t, Options{}, doc, `v = json_parse(doc)
+print json_stringify(v, 0, true)
+unsorted = json_stringify(v, 0, false)
+print len(unsorted) == len(doc) - 12, json_parse(unsorted) == v
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out != sorted+"true true\n" {
+		t.Errorf("printed %q", out)
+	}
+	out, err = runJSON(t, Options{Sandbox: true}, doc, `print json_stringify(json_parse(doc), 0, false)`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out != sorted {
+		t.Errorf("sandboxed VM printed %q, want sorted keys", out)
+	}
+}
+
+func TestJSONErrors(t *testing.T) {
+	for _, tc := range []struct{ doc, src, err string }{
+		{`{"a": 1,}`, `x = json_parse(doc)`, "json_parse: invalid JSON at byte 9"},
+		{`[1, 2`, `x = json_parse(doc)`, "json_parse: invalid JSON at byte 5"},
+		{``, `x = json_stringify(len)`, "json_stringify: cannot encode a function as JSON"},
+		{``, `x = json_stringify(math.nan)`, "json_stringify: cannot encode NaN as JSON"},
+		{``, `x = json_stringify({}, true)`, "json_stringify: argument 2 must be a number or string, not bool"},
+		{``, `x = json_stringify({}, 0, 1)`, "json_stringify: argument 3 must be a bool, not number"},
+		{``, `x = json_stringify({}, -1)`, "json_stringify: indent must not be negative"},
+		{``, `a = {}
+x = push(a, a)
+x = json_stringify(a)`, "json_stringify: cannot encode a list that contains itself"},
+	} {
+		_, err := runJSON(t, Options{}, tc.doc, tc.src)
+		if err == nil || !strings.Contains(err.Error(), tc.err) {
+			t.Errorf("%s: got %v, want %s", tc.src, err, tc.err)
+		}
+	}
+}
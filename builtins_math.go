@@ -0,0 +1,166 @@
+package aurora
+
+import (
+	"errors"
+	"fmt"
+	"math"
+)
+
+var mathBuiltins = []builtin{
+	{"sin", 1, mathFunc(math.Sin)},
+	{"cos", 1, mathFunc(math.Cos)},
+	{"tan", 1, mathFunc(math.Tan)},
+	{"asin", 1, mathFunc(math.Asin)},
+	{"acos", 1, mathFunc(math.Acos)},
+	{"atan", 1, mathFunc(math.Atan)},
+	{"atan2", 2, mathFunc2(math.Atan2)},
+	{"exp", 1, mathFunc(math.Exp)},
+	{"log", Variadic, builtinLog},
+	{"log2", 1, mathFunc(math.Log2)},
+	{"log10", 1, mathFunc(math.Log10)},
+	{"pow", 2, mathFunc2(math.Pow)},
+	{"hypot", 2, mathFunc2(math.Hypot)},
+	{"gcd", 2, builtinGcd},
+	{"lcm", 2, builtinLcm},
+}
+
+// randomBuiltins draw from the VM's random source.
+var randomBuiltins = []builtin{
+	{"random", 0, builtinRandom},
+	{"random_int", 2, builtinRandomInt},
+	{"shuffle", 1, builtinShuffle},
+	{"choice", 1, builtinChoice},
+}
+
+// mathConstants returns the map scripts see as the global math, so the
+// constants are math.pi and math.e rather than names like e that a catch
+// clause would overwrite. Each VM gets its own map.
+func mathConstants() Value {
+	return MapValue(map[string]Value{
+		"pi":  NumberValue(math.Pi),
+		"e":   NumberValue(math.E),
+		"inf": NumberValue(math.Inf(1)),
+		"nan": NumberValue(math.NaN()),
+	})
+}
+
+func mathFunc2(fn func(float64, float64) float64) NativeFunc {
+	return func(vm *AuroraVM, args []Value) (Value, error) {
+		x, err := numberArg(args, 0)
+		if err != nil {
+			return Nil, err
+		}
+		y, err := numberArg(args, 1)
+		if err != nil {
+			return Nil, err
+		}
+		return NumberValue(fn(x, y)), nil
+	}
+}
+
+// builtinLog is the natural logarithm, or the logarithm in the given base.
+func builtinLog(vm *AuroraVM, args []Value) (Value, error) {
+	if err := checkArgCount(args, 1, 2); err != nil {
+		return Nil, err
+	}
+	x, err := numberArg(args, 0)
+	if err != nil {
+		return Nil, err
+	}
+	if len(args) == 1 {
+		return NumberValue(math.Log(x)), nil
+	}
+	base, err := numberArg(args, 1)
+	if err != nil {
+		return Nil, err
+	}
+	return NumberValue(math.Log(x) / math.Log(base)), nil
+}
+
+func gcd(a, b int) int {
+	if a < 0 {
+		a = -a
+	}
+	if b < 0 {
+		b = -b
+	}
+	for b != 0 {
+		a, b = b, a%b
+	}
+	return a
+}
+
+func intArgs2(args []Value) (int, int, error) {
+	a, err := intArg(args, 0)
+	if err != nil {
+		return 0, 0, err
+	}
+	b, err := intArg(args, 1)
+	if err != nil {
+		return 0, 0, err
+	}
+	return a, b, nil
+}
+
+func builtinGcd(vm *AuroraVM, args []Value) (Value, error) {
+	a, b, err := intArgs2(args)
+	if err != nil {
+		return Nil, err
+	}
+	return NumberValue(float64(gcd(a, b))), nil
+}
+
+func builtinLcm(vm *AuroraVM, args []Value) (Value, error) {
+	a, b, err := intArgs2(args)
+	if err != nil {
+		return Nil, err
+	}
+	if a == 0 || b == 0 {
+		return NumberValue(0), nil
+	}
+	lcm := a / gcd(a, b) * b
+	if lcm < 0 {
+		lcm = -lcm
+	}
+	return NumberValue(float64(lcm)), nil
+}
+
+// builtinRandom returns a number in [0, 1).
+func builtinRandom(vm *AuroraVM, args []Value) (Value, error) {
+	return NumberValue(vm.random.Float64()), nil
+}
+
+// builtinRandomInt returns an integer between its arguments, inclusive.
+func builtinRandomInt(vm *AuroraVM, args []Value) (Value, error) {
+	lo, hi, err := intArgs2(args)
+	if err != nil {
+		return Nil, err
+	}
+	if lo > hi {
+		return Nil, fmt.Errorf("empty range %d..%d", lo, hi)
+	}
+	return NumberValue(float64(lo + vm.random.Intn(hi-lo+1))), nil
+}
+
+// builtinShuffle shuffles a list in place.
+func builtinShuffle(vm *AuroraVM, args []Value) (Value, error) {
+	list, err := listArg(args, 0)
+	if err != nil {
+		return Nil, err
+	}
+	vm.random.Shuffle(len(list.items), func(i, j int) {
+		list.items[i], list.items[j] = list.items[j], list.items[i]
+	})
+	return args[0], nil
+}
+
+func builtinChoice(vm *AuroraVM, args []Value) (Value, error) {
+	list, err := listArg(args, 0)
+	if err != nil {
+		return Nil, err
+	}
+	if len(list.items) == 0 {
+		return Nil, errors.New("choice from empty list")
+	}
+	return list.items[vm.random.Intn(len(list.items))], nil
+}
@@ -0,0 +1,85 @@
+package aurora
+
+import (
+	"bytes"
+	"math/rand"
+	"testing"
+)
+
+func TestMathBuiltins(t *testing.T) {
+	runScripts(t, Options{}, []scriptCase{
+		{"constants", `print math.pi, math.e, math.inf, -math.inf, math.nan == math.nan`, "3.141592653589793 2.718281828459045 +Inf -Inf false\n"},
+		{"trigonometry", `print sin(0), cos(0), atan2(1, 1) * 4 == math.pi`, "0 1 true\n"},
+		{"logarithms", `print exp(0), log(math.e), log(8, 2), log2(8), log10(1000)`, "1 1 3 3 3\n"},
+		{"powers", `print pow(2, 10), hypot(3, 4)`, "1024 5\n"},
+		{"gcd and lcm", `print gcd(12, -18), lcm(4, 6), lcm(0, 5)`, "6 12 0\n"},
+		{"catch does not clobber constants", `try
+  throw "boom"
+catch e
+  print e
+end
+print math.e`, "boom\n2.718281828459045\n"},
+	})
+}
+
+func TestMathBuiltinErrors(t *testing.T) {
+	runScriptErrors(t, Options{}, []scriptCase{
+		{"string argument", `x = sin("a")`, "sin: argument 1 must be a number, not string"},
+		{"fractional gcd", `x = gcd(1.5, 2)`, "gcd: argument 1 must be an integer, not 1.5"},
+		{"empty range", `x = random_int(3, 1)`, "random_int: empty range 3..1"},
+		{"empty choice", `x = choice({})`, "choice: choice from empty list"},
+	})
+}
+
+func TestMathConstantsArePerVM(t *testing.T) {
+	if _, err := run(t, Options{}, `math.pi = 3`); err != nil {
+		t.Fatal(err)
+	}
+	runScripts(t, Options{}, []scriptCase{
+		{"fresh constants", `print math.pi`, "3.141592653589793\n"},
+	})
+}
+
+func TestSeededRandomIsReproducible(t *testing.T) {
+	const src = `print random() < 1, random_int(1, 6), choice({"a", "b", "c"})
+xs = {1, 2, 3, 4, 5}
+ys = shuffle(xs)
+print xs
+`
+	var first, second bytes.Buffer
+	for _, out := range []*bytes.Buffer{&first, &second} {
+		if _, err := run(t, Options{Stdout: out, Random: rand.New(rand.NewSource(42))}, src); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if first.String() != second.String() {
+		t.Errorf("same seed printed %q and %q", first.String(), second.String())
+	}
+}
+
+func TestRandomIntStaysInRange(t *testing.T) {
+	vm, err := run(t, Options{Random: rand.New(rand.NewSource(1))}, `seen = {0, 0, 0}
+i = 0
+while i < 300
+  n = random_int(1, 3) - 1
+  count = seen:n
+  seen:n = count + 1
+  i = i + 1
+end
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var seen []int
+	if err := vm.Get("seen", &seen); err != nil {
+		t.Fatal(err)
+	}
+	for i, n := range seen {
+		if n == 0 {
+			t.Errorf("random_int(1, 3) never returned %d", i+1)
+		}
+	}
+	if seen[0]+seen[1]+seen[2] != 300 {
+		t.Errorf("random_int(1, 3) left the range: %v", seen)
+	}
+}
@@ -0,0 +1,169 @@
+package aurora
+
+import (
+	"regexp"
+	"strings"
+)
+
+// Every regex function takes either a regex made by re or a pattern string.
+// Patterns use Go's RE2 syntax.
+var regexBuiltins = []builtin{
+	{"re", 1, builtinRe},
+	{"match", 2, builtinMatch},
+	{"find_all", Variadic, builtinFindAll},
+	{"captures", 2, builtinCaptures},
+	{"replace_re", 3, builtinReplaceRe},
+}
+
+// maxCachedRegexes bounds the per-VM pattern cache. When it fills up the
+// cache is simply emptied; scripts rarely use that many distinct patterns.
+const maxCachedRegexes = 256
+
+// regex compiles pattern, reusing the result of an earlier compilation so
+// that calls in a loop only pay for it once.
+func (vm *AuroraVM) regex(pattern string) (*regexp.Regexp, error) {
+	if re, ok := vm.regexes[pattern]; ok {
+		return re, nil
+	}
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, err
+	}
+	if vm.regexes == nil || len(vm.regexes) >= maxCachedRegexes {
+		vm.regexes = map[string]*regexp.Regexp{}
+	}
+	vm.regexes[pattern] = re
+	return re, nil
+}
+
+func regexArg(vm *AuroraVM, args []Value, i int) (*regexp.Regexp, error) {
+	switch args[i].kind {
+	case RegexKind:
+		return args[i].AsRegex(), nil
+	case StringKind:
+		return vm.regex(args[i].AsString())
+	}
+	return nil, argError(i, "a regex or string", args[i])
+}
+
+func builtinRe(vm *AuroraVM, args []Value) (Value, error) {
+	re, err := regexArg(vm, args, 0)
+	if err != nil {
+		return Nil, err
+	}
+	return RegexValue(re), nil
+}
+
+// regexAndString reads the regex and subject string every function but re
+// starts with.
+func regexAndString(vm *AuroraVM, args []Value) (*regexp.Regexp, string, error) {
+	re, err := regexArg(vm, args, 0)
+	if err != nil {
+		return nil, "", err
+	}
+	s, err := stringArg(args, 1)
+	if err != nil {
+		return nil, "", err
+	}
+	return re, s, nil
+}
+
+// builtinMatch reports whether the regex matches anywhere in the string.
+func builtinMatch(vm *AuroraVM, args []Value) (Value, error) {
+	re, s, err := regexAndString(vm, args)
+	if err != nil {
+		return Nil, err
+	}
+	return BoolValue(re.MatchString(s)), nil
+}
+
+// builtinFindAll returns every match in the string, or at most n of them.
+func builtinFindAll(vm *AuroraVM, args []Value) (Value, error) {
+	if err := checkArgCount(args, 2, 3); err != nil {
+		return Nil, err
+	}
+	re, s, err := regexAndString(vm, args)
+	if err != nil {
+		return Nil, err
+	}
+	n := -1
+	if len(args) == 3 {
+		if n, err = intArg(args, 2); err != nil {
+			return Nil, err
+		}
+	}
+	return stringList(re.FindAllString(s, n)), nil
+}
+
+// submatches converts the submatch indices of one match. A pattern with named
+// groups gives a map from each name to its text; any other pattern gives a
+// list of the whole match followed by each group. Groups that did not take
+// part in the match are nil.
+func submatches(re *regexp.Regexp, s string, loc []int) Value {
+	group := func(i int) Value {
+		if loc[2*i] < 0 {
+			return Nil
+		}
+		return StringValue(s[loc[2*i]:loc[2*i+1]])
+	}
+	names := re.SubexpNames()
+	named := map[string]Value{}
+	for i, name := range names {
+		if name != "" {
+			named[name] = group(i)
+		}
+	}
+	if len(named) > 0 {
+		return MapValue(named)
+	}
+	items := make([]Value, len(names))
+	for i := range items {
+		items[i] = group(i)
+	}
+	return ListValue(items)
+}
+
+// builtinCaptures returns the groups of the first match, or nil when there
+// is none.
+func builtinCaptures(vm *AuroraVM, args []Value) (Value, error) {
+	re, s, err := regexAndString(vm, args)
+	if err != nil {
+		return Nil, err
+	}
+	loc := re.FindStringSubmatchIndex(s)
+	if loc == nil {
+		return Nil, nil
+	}
+	return submatches(re, s, loc), nil
+}
+
+// builtinReplaceRe replaces every match. The replacement is either a string,
+// in which $1 or ${name} expand to groups, or a function called with the
+// captures of each match, as captures would return them, whose result is
+// inserted as a string.
+func builtinReplaceRe(vm *AuroraVM, args []Value) (Value, error) {
+	re, s, err := regexAndString(vm, args)
+	if err != nil {
+		return Nil, err
+	}
+	switch args[2].kind {
+	case StringKind:
+		return StringValue(re.ReplaceAllString(s, args[2].AsString())), nil
+	case FunctionKind, NativeKind:
+	default:
+		return Nil, argError(2, "a string or function", args[2])
+	}
+	var sb strings.Builder
+	last := 0
+	for _, loc := range re.FindAllStringSubmatchIndex(s, -1) {
+		result, err := vm.CallValue(args[2], submatches(re, s, loc))
+		if err != nil {
+			return Nil, err
+		}
+		sb.WriteString(s[last:loc[0]])
+		sb.WriteString(result.String())
+		last = loc[1]
+	}
+	sb.WriteString(s[last:])
+	return StringValue(sb.String()), nil
+}
@@ -0,0 +1,46 @@
+package aurora
+
+import "testing"
+
+func TestRegexBuiltins(t *testing.T) {
+	runScripts(t, Options{}, []scriptCase{
+		{"match", `print match("\d", "a1"), match("^\d", "a1")`, "true false\n"},
+		{"find_all", `print find_all("\d+", "a1b22c333"), find_all("\d+", "a1b22c333", 2), find_all("z", "abc")`, `{"1", "22", "333"} {"1", "22"} {}` + "\n"},
+		{"captures", `print captures("(\w+)@(\w+)", "me@host x"), type(captures("z", "a"))`, `{"me@host", "me", "host"} nil` + "\n"},
+		{"named captures", `d = captures("(?P<y>\d{4})-(?P<m>\d\d)", "on 2024-05")
+print d.y, d.m`, "2024 05\n"},
+		{"unmatched groups are nil", `print captures("(a)|(b)", "b")`, `{"b", nil, "b"}` + "\n"},
+		{"compiled regex", `r = re("o+")
+print type(r), match(r, "foo"), replace_re(r, "foo boo", "0")`, "regex true f0 b0\n"},
+		{"replace with groups", `print replace_re("(\w)(\w*)", "ab cd", "$2$1"), replace_re("(?P<w>\w+)", "hi", "<${w}>")`, "ba dc <hi>\n"},
+		{"replace with a function", `fn up groups -> upper(groups:0)
+print replace_re("[aeiou]", "hello", up)`, "hEllO\n"},
+	})
+}
+
+func TestRegexBuiltinErrors(t *testing.T) {
+	runScriptErrors(t, Options{}, []scriptCase{
+		{"bad pattern", `x = re("(")`, "re: error parsing regexp: missing closing )"},
+		{"bad pattern argument", `x = match(1, "a")`, "match: argument 1 must be a regex or string, not number"},
+		{"bad replacement", `x = replace_re("a", "a", 1)`, "replace_re: argument 3 must be a string or function, not number"},
+		{"failing replacement", `fn boom groups
+  throw "boom"
+end
+x = replace_re("a", "aa", boom)`, "boom"},
+	})
+}
+
+func TestRegexCacheIsBounded(t *testing.T) {
+	vm, err := run(t, Options{}, `i = 0
+while i < 300
+  x = match("a" + str(i), "a1")
+  i = i + 1
+end
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := len(vm.regexes); n == 0 || n > maxCachedRegexes {
+		t.Errorf("cache holds %d patterns, want 1 to %d", n, maxCachedRegexes)
+	}
+}
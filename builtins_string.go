@@ -0,0 +1,229 @@
+package aurora
+
+import (
+	"errors"
+	"math"
+	"strings"
+	"unicode/utf8"
+)
+
+// String functions work in characters (Unicode code points), not bytes, so
+// indices agree with len and with indexing a string.
+var stringBuiltins = []builtin{
+	{"split", Variadic, builtinSplit},
+	{"join", 2, builtinJoin},
+	{"trim", Variadic, builtinTrim},
+	{"replace", Variadic, builtinReplace},
+	{"upper", 1, stringFunc(strings.ToUpper)},
+	{"lower", 1, stringFunc(strings.ToLower)},
+	{"starts_with", 2, stringTest(strings.HasPrefix)},
+	{"ends_with", 2, stringTest(strings.HasSuffix)},
+	{"find", 2, builtinFind},
+	{"repeat", 2, builtinRepeat},
+	{"chars", 1, builtinChars},
+	{"format", Variadic, builtinFormat},
+}
+
+func stringFunc(fn func(string) string) NativeFunc {
+	return func(vm *AuroraVM, args []Value) (Value, error) {
+		s, err := stringArg(args, 0)
+		if err != nil {
+			return Nil, err
+		}
+		return StringValue(fn(s)), nil
+	}
+}
+
+func stringTest(fn func(string, string) bool) NativeFunc {
+	return func(vm *AuroraVM, args []Value) (Value, error) {
+		s, err := stringArg(args, 0)
+		if err != nil {
+			return Nil, err
+		}
+		affix, err := stringArg(args, 1)
+		if err != nil {
+			return Nil, err
+		}
+		return BoolValue(fn(s, affix)), nil
+	}
+}
+
+func stringList(parts []string) Value {
+	items := make([]Value, len(parts))
+	for i, part := range parts {
+		items[i] = StringValue(part)
+	}
+	return ListValue(items)
+}
+
+// builtinSplit splits a string around a separator, or around runs of
+// whitespace when none is given.
+func builtinSplit(vm *AuroraVM, args []Value) (Value, error) {
+	if err := checkArgCount(args, 1, 2); err != nil {
+		return Nil, err
+	}
+	s, err := stringArg(args, 0)
+	if err != nil {
+		return Nil, err
+	}
+	if len(args) == 1 {
+		return stringList(strings.Fields(s)), nil
+	}
+	sep, err := stringArg(args, 1)
+	if err != nil {
+		return Nil, err
+	}
+	return stringList(strings.Split(s, sep)), nil
+}
+
+func builtinJoin(vm *AuroraVM, args []Value) (Value, error) {
+	list, err := listArg(args, 0)
+	if err != nil {
+		return Nil, err
+	}
+	sep, err := stringArg(args, 1)
+	if err != nil {
+		return Nil, err
+	}
+	parts := make([]string, len(list.items))
+	size := int64(len(sep)) * int64(len(parts)-1)
+	for i, item := range list.items {
+		parts[i] = item.String()
+		size += int64(len(parts[i]))
+	}
+	vm.allocate(size)
+	return StringValue(strings.Join(parts, sep)), nil
+}
+
+// builtinTrim strips whitespace, or the given characters, from both ends.
+func builtinTrim(vm *AuroraVM, args []Value) (Value, error) {
+	if err := checkArgCount(args, 1, 2); err != nil {
+		return Nil, err
+	}
+	s, err := stringArg(args, 0)
+	if err != nil {
+		return Nil, err
+	}
+	if len(args) == 1 {
+		return StringValue(strings.TrimSpace(s)), nil
+	}
+	cutset, err := stringArg(args, 1)
+	if err != nil {
+		return Nil, err
+	}
+	return StringValue(strings.Trim(s, cutset)), nil
+}
+
+// builtinReplace replaces every occurrence of old with new, or only the
+// first n when a count is given.
+func builtinReplace(vm *AuroraVM, args []Value) (Value, error) {
+	if err := checkArgCount(args, 3, 4); err != nil {
+		return Nil, err
+	}
+	var parts [3]string
+	for i := range parts {
+		s, err := stringArg(args, i)
+		if err != nil {
+			return Nil, err
+		}
+		parts[i] = s
+	}
+	n := -1
+	if len(args) == 4 {
+		var err error
+		if n, err = intArg(args, 3); err != nil {
+			return Nil, err
+		}
+	}
+	if count := strings.Count(parts[0], parts[1]); n < 0 || count < n {
+		n = count
+	}
+	vm.allocate(int64(len(parts[0])) + int64(n)*int64(len(parts[2])-len(parts[1])))
+	return StringValue(strings.Replace(parts[0], parts[1], parts[2], n)), nil
+}
+
+// builtinFind returns the character index of the first occurrence of sub,
+// or -1.
+func builtinFind(vm *AuroraVM, args []Value) (Value, error) {
+	s, err := stringArg(args, 0)
+	if err != nil {
+		return Nil, err
+	}
+	sub, err := stringArg(args, 1)
+	if err != nil {
+		return Nil, err
+	}
+	i := strings.Index(s, sub)
+	if i < 0 {
+		return NumberValue(-1), nil
+	}
+	return NumberValue(float64(utf8.RuneCountInString(s[:i]))), nil
+}
+
+func builtinRepeat(vm *AuroraVM, args []Value) (Value, error) {
+	s, err := stringArg(args, 0)
+	if err != nil {
+		return Nil, err
+	}
+	n, err := intArg(args, 1)
+	if err != nil {
+		return Nil, err
+	}
+	if n < 0 {
+		return Nil, errors.New("count must not be negative")
+	}
+	if float64(len(s))*float64(n) > math.MaxInt32 {
+		return Nil, errors.New("result is too large")
+	}
+	vm.allocate(int64(len(s)) * int64(n))
+	return StringValue(strings.Repeat(s, n)), nil
+}
+
+func builtinChars(vm *AuroraVM, args []Value) (Value, error) {
+	s, err := stringArg(args, 0)
+	if err != nil {
+		return Nil, err
+	}
+	items := make([]Value, 0, utf8.RuneCountInString(s))
+	for _, r := range s {
+		items = append(items, StringValue(string(r)))
+	}
+	return ListValue(items), nil
+}
+
+// builtinFormat replaces each {} in its first argument with the next
+// argument. {{ and }} stand for literal braces.
+func builtinFormat(vm *AuroraVM, args []Value) (Value, error) {
+	if len(args) == 0 {
+		return Nil, errors.New("expects a format string")
+	}
+	format, err := stringArg(args, 0)
+	if err != nil {
+		return Nil, err
+	}
+	var sb strings.Builder
+	vm.allocate(int64(len(format)))
+	next := 1
+	for i := 0; i < len(format); i++ {
+		switch {
+		case strings.HasPrefix(format[i:], "{{"), strings.HasPrefix(format[i:], "}}"):
+			sb.WriteByte(format[i])
+			i++
+		case strings.HasPrefix(format[i:], "{}"):
+			if next >= len(args) {
+				return Nil, errors.New("not enough arguments for format string")
+			}
+			arg := args[next].String()
+			vm.allocate(int64(len(arg)))
+			sb.WriteString(arg)
+			next++
+			i++
+		default:
+			sb.WriteByte(format[i])
+		}
+	}
+	if next < len(args) {
+		return Nil, errors.New("too many arguments for format string")
+	}
+	return StringValue(sb.String()), nil
+}
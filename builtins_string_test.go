@@ -0,0 +1,34 @@
+package aurora
+
+import "testing"
+
+func TestStringBuiltins(t *testing.T) {
+	runScripts(t, Options{}, []scriptCase{
+		{"split", `print split("a,b,,c", ","), split("  one two  three ")`, `{"a", "b", "", "c"} {"one", "two", "three"}` + "\n"},
+		{"join", `print join({"a", 1, true}, "-")`, "a-1-true\n"},
+		{"trim", `print "[" + trim("  hi  ") + "]", trim("xxhixx", "x")`, "[hi] hi\n"},
+		{"replace", `print replace("aaaa", "a", "b"), replace("aaaa", "a", "b", 2)`, "bbbb bbaa\n"},
+		{"case", `print upper("héllo"), lower("ÀB")`, "HÉLLO àb\n"},
+		{"prefix and suffix", `print starts_with("hello", "he"), ends_with("hello", "lo"), ends_with("hello", "he")`, "true true false\n"},
+		{"find counts characters", `print find("héllo", "l"), find("héllo", "z")`, "2 -1\n"},
+		{"repeat", `print repeat("ab", 3) + "|" + repeat("ab", 0) + "|"`, "ababab||\n"},
+		{"chars", `print chars("hé!")`, `{"h", "é", "!"}` + "\n"},
+		{"format", `print format("{} + {} = {}", 1, 2, 3), format("{{}} {}", {1})`, "1 + 2 = 3 {} {1}\n"},
+		{"index", `s = "héllo"
+print s:0, s:1, s:-1`, "h é o\n"},
+	})
+}
+
+func TestStringBuiltinErrors(t *testing.T) {
+	runScriptErrors(t, Options{}, []scriptCase{
+		{"split a number", `x = split(1, ",")`, "split: argument 1 must be a string, not number"},
+		{"join a string", `x = join("ab", ",")`, "join: argument 1 must be a list, not string"},
+		{"negative repeat", `x = repeat("a", -1)`, "repeat: count must not be negative"},
+		{"huge repeat", `x = repeat("ab", 2000000000)`, "repeat: result is too large"},
+		{"too few format arguments", `x = format("{} {}", 1)`, "format: not enough arguments"},
+		{"too many format arguments", `x = format("{}", 1, 2)`, "format: too many arguments"},
+		{"index out of range", `x = "héllo":5`, "string index 5 out of range for length 5"},
+		{"fractional index", `x = "ab":1.5`, "string index must be an integer, not 1.5"},
+		{"string index", `x = "ab":"a"`, `string index must be an integer, not "a"`},
+	})
+}
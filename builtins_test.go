@@ -0,0 +1,86 @@
+package aurora
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestCoreBuiltins(t *testing.T) {
+	runScripts(t, Options{}, []scriptCase{
+		{"len", `print len({1, 2, 3}), len("héllo"), len("")`, "3 5 0\n"},
+		{"type", `print type({}), type(1), type("a"), type(true), type(len)`, "list number string bool function\n"},
+		{"conversions", `print str(12) + "!", num(" 2.5 ") + 1, int(-2.7), int("7.9")`, "12! 3.5 -2 7\n"},
+		{"push and pop", `xs = {1}
+ys = push(xs, 2, 3)
+last = pop(xs)
+print xs, last, ys`, "{1, 2} 3 {1, 2}\n"},
+		{"insert and remove", `xs = {1, 3}
+ys = insert(xs, 1, 2)
+ys = insert(xs, 3, 4)
+first = remove(xs, 0)
+print first, xs`, "1 {2, 3, 4}\n"},
+		{"slice", `print slice({1, 2, 3}, 1), slice({1, 2, 3}, 0, 2), slice("héllo", 1, 3)`, "{2, 3} {1, 2} él\n"},
+		{"contains", `print contains({1, "a"}, "a"), contains({1}, 2), contains("abc", "bc")`, "true false true\n"},
+		{"sort", `xs = {3, 1, 2}
+ys = sort(xs)
+words = {"b", "c", "a"}
+ys = sort(words)
+print xs, words`, `{1, 2, 3} {"a", "b", "c"}` + "\n"},
+		{"sort with a function", `fn longer a, b -> len(a) > len(b)
+xs = {"aa", "a", "aaa", "bb"}
+ys = sort(xs, longer)
+print xs`, `{"aaa", "aa", "bb", "a"}` + "\n"},
+		{"reverse", `xs = {1, 2, 3}
+ys = reverse(xs)
+print xs, reverse("héllo")`, "{3, 2, 1} olléh\n"},
+		{"min and max", `print min(3, 1, 2), max(3, 1, 2), min({4, 8, 2}), max({4, 8, 2})`, "1 3 2 8\n"},
+		{"math", `print abs(-3), floor(1.5), ceil(1.2), round(2.5), sqrt(16)`, "3 1 2 3 4\n"},
+	})
+}
+
+func TestCoreBuiltinErrors(t *testing.T) {
+	runScriptErrors(t, Options{}, []scriptCase{
+		{"arity", `x = len({}, {})`, "len"},
+		{"pop empty", `x = pop({})`, "pop: pop from empty list"},
+		{"insert out of range", `x = insert({}, 2, 1)`, "insert: index 2 out of range"},
+		{"remove out of range", `x = remove({1}, 1)`, "remove: index 1 out of range"},
+		{"len of a number", `x = len(1)`, "len: argument 1 must be a string, list or map, not number"},
+		{"bad number", `x = num("abc")`, `num: cannot convert "abc" to a number`},
+		{"slice out of bounds", `x = slice({1}, 0, 2)`, "slice: range 0..2 out of bounds"},
+		{"fractional index", `x = slice({1}, 0.5)`, "slice: argument 2 must be an integer"},
+		{"mixed sort", `x = sort({1, "a"})`, "sort: cannot compare"},
+		{"min of nothing", `x = min()`, "min: no values to compare"},
+		{"abs of a string", `x = abs("a")`, "abs: argument 1 must be a number, not string"},
+	})
+}
+
+func TestSortComparatorErrorStopsSort(t *testing.T) {
+	_, err := run(t, Options{}, `fn cmp a, b
+  throw "no order"
+end
+x = sort({2, 1}, cmp)
+`)
+	if err == nil || !strings.Contains(err.Error(), "no order") {
+		t.Errorf("got %v, want the comparator's error", err)
+	}
+}
+
+func TestSortComparatorChangingTheList(t *testing.T) {
+	vm, err := run(t, Options{}, `xs = {5, 3, 8, 1, 9, 2, 7, 4, 6}
+fn cmp a, b
+  if len(xs) > 0
+    x = pop(xs)
+  end
+  return a < b
+end
+ys = sort(xs, cmp)
+n = len(xs)
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var n int
+	if vm.Get("n", &n); n != 9 {
+		t.Errorf("sorted list has %d items, want the 9 it was sorted with", n)
+	}
+}
@@ -0,0 +1,274 @@
+package aurora
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"time"
+
+	// Time zone conversion must not depend on the host having a zoneinfo
+	// database installed.
+	_ "time/tzdata"
+)
+
+// Times are numbers of seconds since the Unix epoch and durations are
+// numbers of seconds, so they combine with ordinary arithmetic. Layouts are
+// Go reference layouts such as "2006-01-02 15:04:05", and zones are IANA
+// names such as "Europe/Paris", "UTC" or "Local". Functions that take a zone
+// default to UTC.
+var timeBuiltins = []builtin{
+	{"format_time", Variadic, builtinFormatTime},
+	{"parse_time", Variadic, builtinParseTime},
+	{"time_parts", Variadic, builtinTimeParts},
+	{"make_time", 1, builtinMakeTime},
+	{"duration", 1, builtinDuration},
+	{"format_duration", 1, builtinFormatDuration},
+}
+
+// clockBuiltins read or wait on the host's clock.
+var clockBuiltins = []builtin{
+	{"now", 0, builtinNow},
+	{"clock", 0, builtinClock},
+	{"sleep", 1, builtinSleep},
+}
+
+func fromTime(t time.Time) Value {
+	return NumberValue(float64(t.UnixNano()) / 1e9)
+}
+
+func toTime(seconds float64) time.Time {
+	whole := math.Floor(seconds)
+	return time.Unix(int64(whole), int64(math.Round((seconds-whole)*1e9)))
+}
+
+func timeArg(args []Value, i int) (time.Time, error) {
+	seconds, err := numberArg(args, i)
+	if err != nil {
+		return time.Time{}, err
+	}
+	if math.IsNaN(seconds) || math.IsInf(seconds, 0) {
+		return time.Time{}, fmt.Errorf("argument %d is not a valid time", i+1)
+	}
+	return toTime(seconds), nil
+}
+
+// zoneArg loads the zone named by the argument at i, or UTC when there are
+// not that many arguments.
+func zoneArg(vm *AuroraVM, args []Value, i int) (*time.Location, error) {
+	if i >= len(args) {
+		return time.UTC, nil
+	}
+	name, err := stringArg(args, i)
+	if err != nil {
+		return nil, err
+	}
+	return vm.loadZone(name)
+}
+
+// loadZone is time.LoadLocation, except that a sandboxed VM does not reveal
+// the host's local zone.
+func (vm *AuroraVM) loadZone(name string) (*time.Location, error) {
+	if vm.sandbox && name == "Local" {
+		return nil, fmt.Errorf("time zone Local: %w", ErrAccessDenied)
+	}
+	return time.LoadLocation(name)
+}
+
+func durationArg(args []Value, i int) (time.Duration, error) {
+	seconds, err := numberArg(args, i)
+	if err != nil {
+		return 0, err
+	}
+	if math.IsNaN(seconds) || math.Abs(seconds) > math.MaxInt64/1e9 {
+		return 0, fmt.Errorf("argument %d is not a valid duration", i+1)
+	}
+	return time.Duration(seconds * 1e9), nil
+}
+
+func builtinNow(vm *AuroraVM, args []Value) (Value, error) {
+	return fromTime(time.Now()), nil
+}
+
+// builtinClock returns the seconds elapsed on a monotonic clock since the VM
+// was created, for measuring intervals unaffected by changes to the wall
+// clock.
+func builtinClock(vm *AuroraVM, args []Value) (Value, error) {
+	return NumberValue(time.Since(vm.start).Seconds()), nil
+}
+
+// builtinSleep pauses for a number of milliseconds. Cancelling the context
+// the VM is running under wakes it early with an error.
+func builtinSleep(vm *AuroraVM, args []Value) (Value, error) {
+	ms, err := numberArg(args, 0)
+	if err != nil {
+		return Nil, err
+	}
+	if ms < 0 || math.IsNaN(ms) {
+		return Nil, errors.New("duration must not be negative")
+	}
+	if ms > math.MaxInt64/1e6 {
+		return Nil, errors.New("duration is too long")
+	}
+	timer := time.NewTimer(time.Duration(ms * float64(time.Millisecond)))
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return Nil, nil
+	case <-vm.ctx.Done():
+		return Nil, vm.ctx.Err()
+	}
+}
+
+// builtinFormatTime formats a time with a layout, in the given zone.
+func builtinFormatTime(vm *AuroraVM, args []Value) (Value, error) {
+	if err := checkArgCount(args, 2, 3); err != nil {
+		return Nil, err
+	}
+	t, err := timeArg(args, 0)
+	if err != nil {
+		return Nil, err
+	}
+	layout, err := stringArg(args, 1)
+	if err != nil {
+		return Nil, err
+	}
+	zone, err := zoneArg(vm, args, 2)
+	if err != nil {
+		return Nil, err
+	}
+	return StringValue(t.In(zone).Format(layout)), nil
+}
+
+// builtinParseTime parses a string with a layout. Times without an offset in
+// them are taken to be in the given zone.
+func builtinParseTime(vm *AuroraVM, args []Value) (Value, error) {
+	if err := checkArgCount(args, 2, 3); err != nil {
+		return Nil, err
+	}
+	s, err := stringArg(args, 0)
+	if err != nil {
+		return Nil, err
+	}
+	layout, err := stringArg(args, 1)
+	if err != nil {
+		return Nil, err
+	}
+	zone, err := zoneArg(vm, args, 2)
+	if err != nil {
+		return Nil, err
+	}
+	t, err := time.ParseInLocation(layout, s, zone)
+	if err != nil {
+		return Nil, err
+	}
+	return fromTime(t), nil
+}
+
+// builtinTimeParts breaks a time down into its calendar fields in the given
+// zone.
+func builtinTimeParts(vm *AuroraVM, args []Value) (Value, error) {
+	if err := checkArgCount(args, 1, 2); err != nil {
+		return Nil, err
+	}
+	t, err := timeArg(args, 0)
+	if err != nil {
+		return Nil, err
+	}
+	zone, err := zoneArg(vm, args, 1)
+	if err != nil {
+		return Nil, err
+	}
+	t = t.In(zone)
+	abbrev, offset := t.Zone()
+	return MapValue(map[string]Value{
+		"year":    NumberValue(float64(t.Year())),
+		"month":   NumberValue(float64(t.Month())),
+		"day":     NumberValue(float64(t.Day())),
+		"hour":    NumberValue(float64(t.Hour())),
+		"minute":  NumberValue(float64(t.Minute())),
+		"second":  NumberValue(float64(t.Second()) + float64(t.Nanosecond())/1e9),
+		"weekday": StringValue(t.Weekday().String()),
+		"yearday": NumberValue(float64(t.YearDay())),
+		"zone":    StringValue(zone.String()),
+		"abbrev":  StringValue(abbrev),
+		"offset":  NumberValue(float64(offset)),
+	}), nil
+}
+
+// builtinMakeTime is the inverse of time_parts. It reads year, month, day,
+// hour, minute, second and zone from a map; only year is required. Fields
+// out of their usual range carry over, so day 32 of January is February 1.
+func builtinMakeTime(vm *AuroraVM, args []Value) (Value, error) {
+	if args[0].kind != MapKind {
+		return Nil, argError(0, "a map", args[0])
+	}
+	parts := args[0].AsMap().items
+	field := func(name string, def int) (int, error) {
+		v, ok := parts[name]
+		if !ok {
+			return def, nil
+		}
+		n, err := intArg([]Value{v}, 0)
+		if err != nil {
+			return 0, fmt.Errorf("%s must be an integer, not %s", name, v.repr())
+		}
+		return n, nil
+	}
+	if _, ok := parts["year"]; !ok {
+		return Nil, errors.New("missing year")
+	}
+	var fields [5]int
+	for i, name := range [...]string{"year", "month", "day", "hour", "minute"} {
+		def := 0
+		if name == "month" || name == "day" {
+			def = 1
+		}
+		n, err := field(name, def)
+		if err != nil {
+			return Nil, err
+		}
+		fields[i] = n
+	}
+	var second float64
+	if v, ok := parts["second"]; ok {
+		if v.kind != NumberKind {
+			return Nil, fmt.Errorf("second must be a number, not %s", v.repr())
+		}
+		second = v.number
+	}
+	zone := time.UTC
+	if v, ok := parts["zone"]; ok {
+		name, err := stringArg([]Value{v}, 0)
+		if err != nil {
+			return Nil, fmt.Errorf("zone must be a string, not %s", v.repr())
+		}
+		if zone, err = vm.loadZone(name); err != nil {
+			return Nil, err
+		}
+	}
+	whole := math.Floor(second)
+	t := time.Date(fields[0], time.Month(fields[1]), fields[2], fields[3], fields[4],
+		int(whole), int(math.Round((second-whole)*1e9)), zone)
+	return fromTime(t), nil
+}
+
+// builtinDuration parses a duration such as "1h30m" or "250ms" into seconds.
+func builtinDuration(vm *AuroraVM, args []Value) (Value, error) {
+	s, err := stringArg(args, 0)
+	if err != nil {
+		return Nil, err
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil {
+		return Nil, err
+	}
+	return NumberValue(d.Seconds()), nil
+}
+
+func builtinFormatDuration(vm *AuroraVM, args []Value) (Value, error) {
+	d, err := durationArg(args, 0)
+	if err != nil {
+		return Nil, err
+	}
+	return StringValue(d.String()), nil
+}
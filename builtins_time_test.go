@@ -0,0 +1,58 @@
+package aurora
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestTimeBuiltins(t *testing.T) {
+	runScripts(t, Options{}, []scriptCase{
+		{"parse and format", `t = parse_time("2024-03-10 14:30:00", "2006-01-02 15:04:05", "Europe/Paris")
+print format_time(t, "2006-01-02 15:04 MST"), format_time(t, "15:04", "Asia/Tokyo")`, "2024-03-10 13:30 UTC 22:30\n"},
+		{"time parts", `p = time_parts(1710077400, "Europe/Paris")
+print p.year, p.month, p.day, p.hour, p.minute, p.second, p.weekday, p.yearday, p.zone, p.abbrev, p.offset`,
+			"2024 3 10 14 30 0 Sunday 70 Europe/Paris CET 3600\n"},
+		{"make time inverts time parts", `t = 1710077400.5
+print make_time(time_parts(t, "America/New_York")) == t`, "true\n"},
+		{"make time carries over", `p = time_parts(0)
+p.year = 2023
+p.day = 32
+print format_time(make_time(p), "2006-01-02")`, "2023-02-01\n"},
+		{"fractional seconds", `print format_time(0.5, "15:04:05.000")`, "00:00:00.500\n"},
+		{"durations", `print duration("1h30m"), duration("250ms"), format_duration(5400.5), format_duration(-2)`, "5400 0.25 1h30m0.5s -2s\n"},
+		{"sleep", `x = sleep(1)
+print clock() > 0`, "true\n"},
+	})
+}
+
+func TestTimeBuiltinErrors(t *testing.T) {
+	runScriptErrors(t, Options{}, []scriptCase{
+		{"negative sleep", `x = sleep(-1)`, "sleep: duration must not be negative"},
+		{"huge sleep", `x = sleep(pow(10, 300))`, "sleep: duration is too long"},
+		{"infinite sleep", `x = sleep(math.inf)`, "sleep: duration is too long"},
+		{"unknown zone", `x = format_time(0, "15", "Mars/Base")`, "format_time: unknown time zone Mars/Base"},
+		{"bad duration", `x = duration("soon")`, `duration: time: invalid duration "soon"`},
+		{"infinite duration", `x = format_duration(math.inf)`, "format_duration: argument 1 is not a valid duration"},
+		{"invalid time", `x = format_time(math.nan, "15")`, "format_time: argument 1 is not a valid time"},
+		{"missing year", `x = make_time(json_parse("{}"))`, "make_time: missing year"},
+	})
+}
+
+func TestSleepWakesWhenCancelled(t *testing.T) {
+	program, err := Compile("x = sleep(60000)\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	err = NewVM(Options{}).RunContext(ctx, program)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("got %v, want the context's deadline", err)
+	}
+	if elapsed := time.Since(start); elapsed > 10*time.Second {
+		t.Errorf("sleep ran for %v after its context was cancelled", elapsed)
+	}
+}
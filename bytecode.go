@@ -0,0 +1,474 @@
+package aurora
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"math"
+	"strings"
+)
+
+// Precompiled scripts are stored in .auc files laid out as
+//
+//	magic    [4]byte  "AURC"
+//	version  uint16   BytecodeVersion
+//	length   uint32   payload size in bytes
+//	checksum uint32   CRC-32 (IEEE) of the payload
+//	payload           the top-level chunk
+//
+// Fixed-size integers are big-endian. A chunk is its local and register
+// counts as uvarints followed by its code, its line table, its constant pool
+// and its exception handlers, each prefixed by a uvarint count. A handler is
+// its start, end and target offsets and its register, all uvarints. Function
+// constants carry their own chunk, so the whole tree is written in a single
+// pass.
+const BytecodeVersion = 7
+
+var bytecodeMagic = []byte("AURC")
+
+const bytecodeHeaderSize = 14
+
+const (
+	constNil byte = iota
+	constNumber
+	constString
+	constBool
+	constFunction
+)
+
+var (
+	ErrNotBytecode     = errors.New("not an Aurora bytecode file")
+	ErrCorruptBytecode = errors.New("corrupt Aurora bytecode file")
+)
+
+type BytecodeVersionError struct {
+	Version uint16
+}
+
+func (e BytecodeVersionError) Error() string {
+	return fmt.Sprintf("bytecode version %d is not supported (expected version %d)", e.Version, BytecodeVersion)
+}
+
+// IsBytecode reports whether data starts with the .auc magic number.
+func IsBytecode(data []byte) bool {
+	return bytes.HasPrefix(data, bytecodeMagic)
+}
+
+// WriteBytecode writes program to w in the .auc format.
+func WriteBytecode(w io.Writer, program *Program) error {
+	payload := &bytecodeWriter{}
+	if err := payload.chunk(program.script.body); err != nil {
+		return err
+	}
+	header := make([]byte, bytecodeHeaderSize)
+	copy(header, bytecodeMagic)
+	binary.BigEndian.PutUint16(header[4:], BytecodeVersion)
+	binary.BigEndian.PutUint32(header[6:], uint32(payload.buf.Len()))
+	binary.BigEndian.PutUint32(header[10:], crc32.ChecksumIEEE(payload.buf.Bytes()))
+	if _, err := w.Write(header); err != nil {
+		return err
+	}
+	_, err := w.Write(payload.buf.Bytes())
+	return err
+}
+
+// ReadBytecode loads a program written by WriteBytecode. It rejects files
+// from other format versions with a BytecodeVersionError, and files whose
+// code refers to registers, constants or jump targets that do not exist
+// with ErrCorruptBytecode.
+func ReadBytecode(r io.Reader) (*Program, error) {
+	header := make([]byte, bytecodeHeaderSize)
+	if _, err := io.ReadFull(r, header); err != nil {
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			return nil, ErrNotBytecode
+		}
+		return nil, err
+	}
+	if !IsBytecode(header) {
+		return nil, ErrNotBytecode
+	}
+	if version := binary.BigEndian.Uint16(header[4:]); version != BytecodeVersion {
+		return nil, BytecodeVersionError{version}
+	}
+	// the payload grows as it arrives rather than being allocated up front,
+	// so a header claiming gigabytes costs nothing unless they are there
+	size := int64(binary.BigEndian.Uint32(header[6:]))
+	var buf bytes.Buffer
+	if _, err := buf.ReadFrom(io.LimitReader(r, size)); err != nil {
+		return nil, err
+	}
+	if int64(buf.Len()) != size {
+		return nil, ErrCorruptBytecode
+	}
+	payload := buf.Bytes()
+	if crc32.ChecksumIEEE(payload) != binary.BigEndian.Uint32(header[10:]) {
+		return nil, fmt.Errorf("%w: checksum mismatch", ErrCorruptBytecode)
+	}
+	reader := &bytecodeReader{bytes.NewReader(payload)}
+	chunk, err := reader.chunk()
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrCorruptBytecode, err)
+	}
+	if reader.r.Len() != 0 {
+		return nil, fmt.Errorf("%w: trailing data", ErrCorruptBytecode)
+	}
+	return newProgram(chunk), nil
+}
+
+type bytecodeWriter struct {
+	buf bytes.Buffer
+}
+
+func (w *bytecodeWriter) uvarint(n uint64) {
+	var b [binary.MaxVarintLen64]byte
+	w.buf.Write(b[:binary.PutUvarint(b[:], n)])
+}
+
+func (w *bytecodeWriter) string(s string) {
+	w.uvarint(uint64(len(s)))
+	w.buf.WriteString(s)
+}
+
+func (w *bytecodeWriter) chunk(chunk *chunk) error {
+	w.uvarint(uint64(chunk.locals))
+	w.uvarint(uint64(chunk.registers))
+	w.uvarint(uint64(len(chunk.code)))
+	w.buf.Write(chunk.code)
+	w.uvarint(uint64(len(chunk.lines)))
+	for _, line := range chunk.lines {
+		w.uvarint(uint64(line))
+	}
+	w.uvarint(uint64(len(chunk.constants)))
+	for _, constant := range chunk.constants {
+		if err := w.constant(constant); err != nil {
+			return err
+		}
+	}
+	w.uvarint(uint64(len(chunk.handlers)))
+	for _, h := range chunk.handlers {
+		w.uvarint(uint64(h.start))
+		w.uvarint(uint64(h.end))
+		w.uvarint(uint64(h.target))
+		w.uvarint(uint64(h.register))
+	}
+	return nil
+}
+
+func (w *bytecodeWriter) constant(constant Value) error {
+	switch constant.kind {
+	case NilKind:
+		w.buf.WriteByte(constNil)
+	case NumberKind:
+		w.buf.WriteByte(constNumber)
+		var b [8]byte
+		binary.BigEndian.PutUint64(b[:], math.Float64bits(constant.AsNumber()))
+		w.buf.Write(b[:])
+	case StringKind:
+		w.buf.WriteByte(constString)
+		w.string(constant.AsString())
+	case BoolKind:
+		w.buf.WriteByte(constBool)
+		if constant.AsBool() {
+			w.buf.WriteByte(1)
+		} else {
+			w.buf.WriteByte(0)
+		}
+	case FunctionKind:
+		f := constant.AsFunction()
+		w.buf.WriteByte(constFunction)
+		w.string(f.name)
+		w.uvarint(uint64(len(f.args)))
+		for _, arg := range f.args {
+			w.string(arg)
+		}
+		w.uvarint(uint64(f.arity))
+		return w.chunk(f.body)
+	default:
+		return fmt.Errorf("cannot serialize %s constant", constant.kind)
+	}
+	return nil
+}
+
+type bytecodeReader struct {
+	r *bytes.Reader
+}
+
+// count reads a length prefix, rejecting any that could not possibly fit in
+// the remaining payload.
+func (r *bytecodeReader) count() (int, error) {
+	n, err := binary.ReadUvarint(r.r)
+	if err != nil {
+		return 0, err
+	}
+	if n > uint64(r.r.Len()) {
+		return 0, fmt.Errorf("length %d exceeds remaining %d bytes", n, r.r.Len())
+	}
+	return int(n), nil
+}
+
+func (r *bytecodeReader) string() (string, error) {
+	n, err := r.count()
+	if err != nil {
+		return "", err
+	}
+	b := make([]byte, n)
+	if _, err := io.ReadFull(r.r, b); err != nil {
+		return "", err
+	}
+	return string(b), nil
+}
+
+func (r *bytecodeReader) chunk() (*chunk, error) {
+	locals, err := binary.ReadUvarint(r.r)
+	if err != nil {
+		return nil, err
+	}
+	registers, err := binary.ReadUvarint(r.r)
+	if err != nil {
+		return nil, err
+	}
+	if locals > 0x100 || registers > 0x100 {
+		return nil, fmt.Errorf("chunk needs %d locals and %d registers", locals, registers)
+	}
+	n, err := r.count()
+	if err != nil {
+		return nil, err
+	}
+	chunk := &chunk{code: make([]byte, n), locals: int(locals), registers: int(registers)}
+	if _, err := io.ReadFull(r.r, chunk.code); err != nil {
+		return nil, err
+	}
+	if n, err = r.count(); err != nil {
+		return nil, err
+	}
+	if n != len(chunk.code) {
+		return nil, fmt.Errorf("line table has %d entries for %d bytes of code", n, len(chunk.code))
+	}
+	chunk.lines = make([]int, n)
+	for i := range chunk.lines {
+		line, err := binary.ReadUvarint(r.r)
+		if err != nil {
+			return nil, err
+		}
+		chunk.lines[i] = int(line)
+	}
+	if n, err = r.count(); err != nil {
+		return nil, err
+	}
+	if n > 0x100 {
+		return nil, fmt.Errorf("%d constants in one chunk", n)
+	}
+	chunk.constants = make([]Value, n)
+	for i := range chunk.constants {
+		if chunk.constants[i], err = r.constant(); err != nil {
+			return nil, err
+		}
+	}
+	if n, err = r.count(); err != nil {
+		return nil, err
+	}
+	chunk.handlers = make([]handler, n)
+	for i := range chunk.handlers {
+		var fields [4]uint64
+		for j := range fields {
+			if fields[j], err = binary.ReadUvarint(r.r); err != nil {
+				return nil, err
+			}
+		}
+		start, end, target, register := fields[0], fields[1], fields[2], fields[3]
+		if start > end || end > uint64(len(chunk.code)) || target >= uint64(len(chunk.code)) || register >= registers {
+			return nil, fmt.Errorf("handler %d is out of range", i)
+		}
+		chunk.handlers[i] = handler{int(start), int(end), int(target), uint8(register)}
+	}
+	if err := verify(chunk); err != nil {
+		return nil, err
+	}
+	return chunk, nil
+}
+
+// operands lists the operands of each instruction, one letter each: r is a
+// register, B the first of a run of registers whose length is given by the
+// instruction's counts, l a local, c a constant, s a string constant, n a
+// count, k a value kind, b any other byte, j a forward jump and L a backward
+// one.
+var operands = [...]string{
+	opLoad:           "cr",
+	opStore:          "rl",
+	opStoreGlobal:    "rs",
+	opLoadLocal:      "lr",
+	opLoadGlobal:     "sr",
+	opAdd:            "rrr",
+	opAddTo:          "rr",
+	opSub:            "rrr",
+	opSubFrom:        "rr",
+	opMul:            "rrr",
+	opDiv:            "rrr",
+	opMod:            "rrr",
+	opNeg:            "rr",
+	opNot:            "rr",
+	opEqual:          "rrr",
+	opNotEqual:       "rrr",
+	opLess:           "rrr",
+	opLessEqual:      "rrr",
+	opGreater:        "rrr",
+	opGreaterEqual:   "rrr",
+	opJump:           "j",
+	opJumpIfFalse:    "rj",
+	opJumpIfTrue:     "rj",
+	opJumpIfEqual:    "rrj",
+	opJumpIfNotEqual: "rrj",
+	opLoop:           "L",
+	opCall:           "rnBr",
+	opReturn:         "r",
+	opIndex:          "rrr",
+	opIndexAssign:    "rrr",
+	opList:           "Bnr",
+	opIterate:        "rrrj",
+	opThrow:          "r",
+	opSlice:          "rrrr",
+	opUnpack:         "rnB",
+	opJumpIfNotKind:  "rkj",
+	opJumpIfNotLen:   "rbbj",
+	opJumpIfNoKey:    "rrj",
+	opType:           "Bnnr",
+	opMethod:         "rsr",
+}
+
+// verify checks that the code of chunk only refers to registers, locals and
+// constants it has, that its jumps land on instructions and that it cannot
+// run off its end, so that a crafted file fails to load rather than
+// crashing the VM.
+func verify(chunk *chunk) error {
+	code := chunk.code
+	starts := make([]bool, len(code))
+	var targets []int
+	last := opcode(0)
+	for pc := 0; pc < len(code); {
+		starts[pc] = true
+		op := opcode(code[pc])
+		if int(op) >= len(operands) {
+			return fmt.Errorf("unknown opcode %d at %d", op, pc)
+		}
+		format := operands[op]
+		start := pc
+		pc++
+		if pc+len(format)+strings.Count(format, "j")+strings.Count(format, "L") > len(code) {
+			return fmt.Errorf("truncated instruction at %d", start)
+		}
+		// the run of registers a B operand starts
+		base, span := 0, 0
+		for _, operand := range format {
+			b := int(code[pc])
+			pc++
+			switch operand {
+			case 'r':
+				if b >= chunk.registers {
+					return fmt.Errorf("register %d out of range at %d", b, start)
+				}
+			case 'l':
+				if b >= chunk.locals {
+					return fmt.Errorf("local %d out of range at %d", b, start)
+				}
+			case 'c', 's':
+				if b >= len(chunk.constants) {
+					return fmt.Errorf("constant %d out of range at %d", b, start)
+				}
+				if operand == 's' && chunk.constants[b].kind != StringKind {
+					return fmt.Errorf("constant %d is not a name at %d", b, start)
+				}
+			case 'B':
+				base = b
+			case 'n':
+				span += b
+			case 'k':
+				if b >= len(kindNames) {
+					return fmt.Errorf("unknown kind %d at %d", b, start)
+				}
+			case 'j', 'L':
+				offset := b<<8 | int(code[pc])
+				pc++
+				if operand == 'j' {
+					targets = append(targets, start+1+len(format)+1+offset)
+				} else {
+					targets = append(targets, start+1+len(format)+1-offset)
+				}
+			}
+		}
+		if op == opType {
+			// the type's name comes before its fields and methods
+			span++
+		}
+		if base+span > chunk.registers {
+			return fmt.Errorf("registers %d to %d out of range at %d", base, base+span, start)
+		}
+		last = op
+	}
+	if len(code) == 0 || (last != opReturn && last != opJump && last != opLoop && last != opThrow) {
+		return errors.New("code does not end in a return")
+	}
+	for _, h := range chunk.handlers {
+		targets = append(targets, h.target)
+	}
+	for _, target := range targets {
+		if target < 0 || target >= len(code) || !starts[target] {
+			return fmt.Errorf("jump to %d is not to an instruction", target)
+		}
+	}
+	return nil
+}
+
+func (r *bytecodeReader) constant() (Value, error) {
+	tag, err := r.r.ReadByte()
+	if err != nil {
+		return Nil, err
+	}
+	switch tag {
+	case constNil:
+		return Nil, nil
+	case constNumber:
+		var b [8]byte
+		if _, err := io.ReadFull(r.r, b[:]); err != nil {
+			return Nil, err
+		}
+		return NumberValue(math.Float64frombits(binary.BigEndian.Uint64(b[:]))), nil
+	case constString:
+		s, err := r.string()
+		return StringValue(s), err
+	case constBool:
+		b, err := r.r.ReadByte()
+		if err != nil {
+			return Nil, err
+		}
+		return BoolValue(b != 0), nil
+	case constFunction:
+		name, err := r.string()
+		if err != nil {
+			return Nil, err
+		}
+		n, err := r.count()
+		if err != nil {
+			return Nil, err
+		}
+		args := make([]string, n)
+		for i := range args {
+			if args[i], err = r.string(); err != nil {
+				return Nil, err
+			}
+		}
+		arity, err := binary.ReadUvarint(r.r)
+		if err != nil {
+			return Nil, err
+		}
+		body, err := r.chunk()
+		if err != nil {
+			return Nil, err
+		}
+		return FunctionValue(&AuroraFunction{name, args, int(arity), body}), nil
+	default:
+		return Nil, fmt.Errorf("unknown constant tag %d", tag)
+	}
+}
@@ -0,0 +1,117 @@
+package aurora
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"math"
+	"runtime"
+	"testing"
+)
+
+func TestReadBytecodeRejectsBadOperands(t *testing.T) {
+	chunks := map[string]*chunk{
+		"register":  {code: []byte{byte(opReturn), 1}, lines: []int{1, 1}, registers: 1},
+		"constant":  {code: []byte{byte(opLoad), 0, 0, byte(opReturn), 0}, lines: make([]int, 5), registers: 1},
+		"opcode":    {code: []byte{0xff}, lines: []int{1}},
+		"jump":      {code: []byte{byte(opJump), 0, 1, byte(opReturn), 0}, lines: make([]int, 5), registers: 1},
+		"truncated": {code: []byte{byte(opReturn)}, lines: []int{1}, registers: 1},
+		"no return": {code: []byte{byte(opNot), 0, 0}, lines: make([]int, 3), registers: 1},
+	}
+	for name, chunk := range chunks {
+		var buf bytes.Buffer
+		if err := WriteBytecode(&buf, newProgram(chunk)); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := ReadBytecode(&buf); !errors.Is(err, ErrCorruptBytecode) {
+			t.Errorf("%s: got %v, want ErrCorruptBytecode", name, err)
+		}
+	}
+}
+
+// roundTripScripts cover every kind of instruction the compiler emits.
+var roundTripScripts = map[string]string{
+	"recursion": `fn fib n
+  if n < 2
+    return n
+  end
+  return fib(n - 1) + fib(n - 2)
+end
+print fib(10)
+`,
+	"match": `fn shape xs
+  match xs
+  case {a, b, c, d, e, f, g, h} -> return a + h
+  case {first, ...rest} -> return rest
+  case {"k": v} -> return v
+  case _ -> return "other"
+  end
+end
+print shape({1, 2, 3, 4, 5, 6, 7, 8}), shape({1, 2, 3}), shape(7)
+`,
+	"records": `type Vec x, y
+  fn __add other -> Vec(self.x + other.x, self.y + other.y)
+  fn norm2 -> self.x * self.x + self.y * self.y
+end
+v = Vec(1, 2) + Vec(2, 2)
+print v, v.norm2()
+`,
+	"try": `fn f
+  for i, {1, 2, 3}
+    try
+      if i == 2
+        throw "two"
+      end
+    catch e
+      print "caught", e.message
+    finally
+      print "finally", i
+    end
+  end
+end
+x = f()
+`,
+}
+
+func TestBytecodeRoundTrip(t *testing.T) {
+	for name, src := range roundTripScripts {
+		program, err := Compile(src)
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		var buf bytes.Buffer
+		if err := WriteBytecode(&buf, program); err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		loaded, err := ReadBytecode(&buf)
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		var want, got bytes.Buffer
+		if err := NewVM(Options{Stdout: &want}).Run(program); err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if err := NewVM(Options{Stdout: &got}).Run(loaded); err != nil {
+			t.Fatalf("%s: loaded: %v", name, err)
+		}
+		if got.String() != want.String() {
+			t.Errorf("%s: loaded program printed %q, want %q", name, got.String(), want.String())
+		}
+	}
+}
+
+func TestReadBytecodeDoesNotTrustPayloadSize(t *testing.T) {
+	header := make([]byte, bytecodeHeaderSize)
+	copy(header, bytecodeMagic)
+	binary.BigEndian.PutUint16(header[4:], BytecodeVersion)
+	binary.BigEndian.PutUint32(header[6:], math.MaxUint32)
+	var before, after runtime.MemStats
+	runtime.ReadMemStats(&before)
+	if _, err := ReadBytecode(bytes.NewReader(header)); !errors.Is(err, ErrCorruptBytecode) {
+		t.Errorf("got %v, want ErrCorruptBytecode", err)
+	}
+	runtime.ReadMemStats(&after)
+	if allocated := after.TotalAlloc - before.TotalAlloc; allocated > 1<<20 {
+		t.Errorf("reading a 14-byte file allocated %d bytes", allocated)
+	}
+}
@@ -0,0 +1,138 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"aurorago"
+)
+
+const usage = `usage:
+  aurora build [-o output.auc] <script>   compile a script to bytecode
+  aurora run [flags] <script | bytecode.auc> [args...]
+                                          run a script or precompiled bytecode
+
+run flags:
+  -allow-read paths    comma-separated files and directories scripts may read
+  -allow-write paths   comma-separated files and directories scripts may write
+  -allow-env names     comma-separated environment variables scripts may read
+  -sandbox             leave out the clock, randomness and other ambient access`
+
+func main() {
+	if len(os.Args) < 2 {
+		fmt.Fprintln(os.Stderr, usage)
+		os.Exit(2)
+	}
+	var err error
+	switch os.Args[1] {
+	case "build":
+		err = build(os.Args[2:])
+	case "run":
+		err = run(os.Args[2:])
+	default:
+		fmt.Fprintln(os.Stderr, usage)
+		os.Exit(2)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "aurora:", err)
+		os.Exit(1)
+	}
+}
+
+func build(args []string) error {
+	flags := flag.NewFlagSet("build", flag.ExitOnError)
+	output := flags.String("o", "", "output file (default: the script name with an .auc extension)")
+	flags.Parse(args)
+	if flags.NArg() != 1 {
+		return fmt.Errorf("build takes exactly one script\n%s", usage)
+	}
+	path := flags.Arg(0)
+	src, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	program, err := aurora.Compile(string(src))
+	if err != nil {
+		return fmt.Errorf("%s: %v", path, err)
+	}
+	printWarnings(path, program)
+	if *output == "" {
+		*output = strings.TrimSuffix(path, filepath.Ext(path)) + ".auc"
+	}
+	var buf bytes.Buffer
+	if err := aurora.WriteBytecode(&buf, program); err != nil {
+		return err
+	}
+	return os.WriteFile(*output, buf.Bytes(), 0644)
+}
+
+// loadProgram decodes path directly when it is precompiled bytecode and
+// compiles it from source otherwise.
+func loadProgram(path string) (*aurora.Program, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	if aurora.IsBytecode(data) {
+		program, err := aurora.ReadBytecode(bytes.NewReader(data))
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		return program, nil
+	}
+	program, err := aurora.Compile(string(data))
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	printWarnings(path, program)
+	return program, nil
+}
+
+func printWarnings(path string, program *aurora.Program) {
+	for _, warning := range program.Warnings() {
+		fmt.Fprintf(os.Stderr, "aurora: %s: warning: %s\n", path, warning)
+	}
+}
+
+// splitList splits a comma-separated flag value, ignoring empty entries.
+func splitList(s string) []string {
+	var items []string
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+func run(args []string) error {
+	flags := flag.NewFlagSet("run", flag.ExitOnError)
+	allowRead := flags.String("allow-read", "", "comma-separated paths scripts may read")
+	allowWrite := flags.String("allow-write", "", "comma-separated paths scripts may write")
+	allowEnv := flags.String("allow-env", "", "comma-separated environment variables scripts may read")
+	sandbox := flags.Bool("sandbox", false, "leave out the clock, randomness and other ambient access")
+	flags.Parse(args)
+	if flags.NArg() < 1 {
+		return fmt.Errorf("run takes a file\n%s", usage)
+	}
+	program, err := loadProgram(flags.Arg(0))
+	if err != nil {
+		return err
+	}
+	vm := aurora.NewVM(aurora.Options{
+		Capabilities: aurora.Capabilities{
+			ReadPaths:  splitList(*allowRead),
+			WritePaths: splitList(*allowWrite),
+			Env:        splitList(*allowEnv),
+			Args:       append([]string{}, flags.Args()[1:]...),
+		},
+		// print is the one ambient capability a sandboxed command keeps
+		Stdout:  os.Stdout,
+		Sandbox: *sandbox,
+	})
+	return vm.Run(program)
+}
@@ -1,144 +1,834 @@
-package main
+package aurora
 
-var currentChunk *Chunk
+import "fmt"
 
-func beginCompile() {
-	currentChunk = &Chunk{
-		code:      []byte{},
-		lines:     []int{},
-		constants: []any{},
+type loopContext struct {
+	start  int
+	breaks []int
+}
+
+// finallyContext is a finally block that break, continue and return must
+// run before they leave its try or catch block.
+type finallyContext struct {
+	body  []node
+	loops int // the number of enclosing loops
+	try   int // the index of its try statement in tries
+}
+
+// tryContext is a try statement being compiled. excluded holds the code its
+// handlers must not cover: the copies of finally blocks that run as control
+// leaves it, whose errors belong to the statements around it.
+type tryContext struct {
+	excluded [][2]int
+}
+
+// compiler holds the state for the chunk currently being emitted. Function
+// bodies are compiled with a fresh compiler of their own, which shares the
+// warnings of the program it is part of. Nothing is global, so programs can
+// be compiled concurrently.
+type compiler struct {
+	chunk     *chunk
+	chunkType chunkType
+	locals    []string
+	registers int
+	maxRegs   int
+	loops     []*loopContext
+	finallies []finallyContext
+	tries     []*tryContext
+	line      int
+	warnings  *[]string
+}
+
+func newCompiler(chunkType chunkType, warnings *[]string) *compiler {
+	return &compiler{
+		chunk: &chunk{
+			code:      []byte{},
+			lines:     []int{},
+			constants: []Value{},
+		},
+		chunkType: chunkType,
+		warnings:  warnings,
 	}
 }
 
-func emitByte(b byte) {
-	currentChunk.code = append(currentChunk.code, b)
+func (c *compiler) warn(format string, args ...any) {
+	*c.warnings = append(*c.warnings, fmt.Sprintf("line %d: ", c.line)+fmt.Sprintf(format, args...))
 }
 
-func emitOp(op Opcode) {
-	emitByte(byte(op))
+// finish returns the compiled chunk.
+func (c *compiler) finish() *chunk {
+	c.chunk.locals = len(c.locals)
+	c.chunk.registers = c.maxRegs
+	return c.chunk
+}
+
+func compileProgram(nodes []node) (*chunk, []string) {
+	var warnings []string
+	c := newCompiler(typeProgram, &warnings)
+	c.compileBlock(nodes)
+	c.emitNilReturn()
+	return c.finish(), warnings
+}
+
+// compileSource parses and compiles a whole script, turning parse and compile
+// panics into an error.
+func compileSource(src string) (chunk *chunk, warnings []string, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("%v", r)
+		}
+	}()
+	parser := newParser(newLexer(src))
+	chunk, warnings = compileProgram(parser.program())
+	return chunk, warnings, nil
+}
+
+func (c *compiler) compileBlock(nodes []node) {
+	for _, n := range nodes {
+		registers := c.registers
+		n.compile(c)
+		c.registers = registers
+	}
 }
 
-func emitConstant(value any) {
-	emitOp(OpLoad)
-	emitByte(byte(len(currentChunk.constants)))
-	currentChunk.constants = append(currentChunk.constants, value)
+func (c *compiler) setLine(line int) {
+	c.line = line
 }
 
-func emitJump(op Opcode) int {
-	emitOp(op)
-	emitByte(0xff)
-	emitByte(0xff)
-	return len(currentChunk.code) - 2
+func (c *compiler) emitByte(b byte) {
+	c.chunk.code = append(c.chunk.code, b)
+	c.chunk.lines = append(c.chunk.lines, c.line)
 }
 
-func emitLoop(start int) {
-	emitOp(OpLoop)
-	offset := len(currentChunk.code) - start + 2
+func (c *compiler) emitOp(op opcode) {
+	c.emitByte(byte(op))
+}
+
+func (c *compiler) makeConstant(value Value) byte {
+	switch value.kind {
+	case NilKind, BoolKind, NumberKind, StringKind:
+		for i, constant := range c.chunk.constants {
+			if valuesEqual(constant, value) {
+				return byte(i)
+			}
+		}
+	}
+	if len(c.chunk.constants) > 0xff {
+		panic(fmt.Sprintf("Too many constants in one chunk at line %d", c.line))
+	}
+	c.chunk.constants = append(c.chunk.constants, value)
+	return byte(len(c.chunk.constants) - 1)
+}
+
+func (c *compiler) emitConstant(value Value, register byte) {
+	c.emitOp(opLoad)
+	c.emitByte(c.makeConstant(value))
+	c.emitByte(register)
+}
+
+func (c *compiler) emitJump(op opcode, operands ...byte) int {
+	c.emitOp(op)
+	for _, operand := range operands {
+		c.emitByte(operand)
+	}
+	c.emitByte(0xff)
+	c.emitByte(0xff)
+	return len(c.chunk.code) - 2
+}
+
+func (c *compiler) emitLoop(start int) {
+	c.emitOp(opLoop)
+	offset := len(c.chunk.code) - start + 2
 	if offset > 0xffff {
 		panic("Loop body too large.")
 	}
-	emitByte(byte(offset >> 8))
-	emitByte(byte(offset))
+	c.emitByte(byte(offset >> 8))
+	c.emitByte(byte(offset))
 }
 
-func patchJump(offset int) {
-	jump := len(currentChunk.code) - offset - 2
+func (c *compiler) patchJump(offset int) {
+	jump := len(c.chunk.code) - offset - 2
 	if jump > 0xffff {
 		panic("Too much code to jump over.")
 	}
-	currentChunk.code[offset] = byte(jump >> 8)
-	currentChunk.code[offset+1] = byte(jump)
+	c.chunk.code[offset] = byte(jump >> 8)
+	c.chunk.code[offset+1] = byte(jump)
 }
 
-func (i If) compile() {
-	i.Cond.compile()
-	falseJump := emitJump(OpJumpIfFalse)
-	for _, n := range i.Then {
-		n.compile()
+// Registers are handed out like a stack: every expression allocates exactly
+// one register and leaves its value there, and statements release whatever
+// their expressions allocated.
+func (c *compiler) allocRegister() byte {
+	if c.registers > 0xff {
+		panic(fmt.Sprintf("Expression too complex at line %d", c.line))
 	}
-	endJump := emitJump(OpJump)
-	patchJump(falseJump)
-	for _, n := range i.Else {
-		n.compile()
+	c.registers++
+	if c.registers > c.maxRegs {
+		c.maxRegs = c.registers
 	}
-	patchJump(endJump)
+	return byte(c.registers - 1)
+}
+
+func (c *compiler) freeRegisters(n int) {
+	c.registers -= n
+}
+
+func (c *compiler) topRegister() byte {
+	return byte(c.registers - 1)
 }
 
-func (w While) compile() {
-	loopStart := len(currentChunk.code)
-	w.Cond.compile()
-	exitJump := emitJump(OpJumpIfFalse)
-	for _, n := range w.Body {
-		n.compile()
+func (c *compiler) resolveLocal(name string) int {
+	for i := len(c.locals) - 1; i >= 0; i-- {
+		if c.locals[i] == name {
+			return i
+		}
 	}
-	emitLoop(loopStart)
-	patchJump(exitJump)
+	return -1
 }
 
-func (f For) compile() {
+func (c *compiler) declareLocal(name string) int {
+	if len(c.locals) > 0xff {
+		panic(fmt.Sprintf("Too many local variables at line %d", c.line))
+	}
+	c.locals = append(c.locals, name)
+	return len(c.locals) - 1
+}
 
+func (c *compiler) emitLoadVariable(name string, register byte) {
+	if local := c.resolveLocal(name); local >= 0 {
+		c.emitOp(opLoadLocal)
+		c.emitByte(byte(local))
+		c.emitByte(register)
+		return
+	}
+	c.emitOp(opLoadGlobal)
+	c.emitByte(c.makeConstant(StringValue(name)))
+	c.emitByte(register)
 }
 
-func (f Func) compile() {
+// Top-level assignments define globals; inside a function, assigning to a
+// name that is not yet a local declares one.
+func (c *compiler) emitStoreVariable(name string, register byte) {
+	if c.chunkType == typeProgram {
+		c.emitOp(opStoreGlobal)
+		c.emitByte(register)
+		c.emitByte(c.makeConstant(StringValue(name)))
+		return
+	}
+	local := c.resolveLocal(name)
+	if local < 0 {
+		local = c.declareLocal(name)
+	}
+	c.emitOp(opStore)
+	c.emitByte(register)
+	c.emitByte(byte(local))
+}
 
+var binaryOps = map[operatorType]opcode{
+	plus:         opAdd,
+	minus:        opSub,
+	multiply:     opMul,
+	divide:       opDiv,
+	modulo:       opMod,
+	equal:        opEqual,
+	notEqual:     opNotEqual,
+	less:         opLess,
+	lessEqual:    opLessEqual,
+	greater:      opGreater,
+	greaterEqual: opGreaterEqual,
 }
 
-func (s Sub) compile() {
+var compoundOps = map[operatorType]operatorType{
+	plusAssign:     plus,
+	minusAssign:    minus,
+	multiplyAssign: multiply,
+	divideAssign:   divide,
+}
 
+// emitBinary applies op to the two topmost registers, leaving the result in
+// the lower one.
+func (c *compiler) emitBinary(op operatorType) {
+	left := byte(c.registers - 2)
+	c.emitOp(binaryOps[op])
+	c.emitByte(left)
+	c.emitByte(left + 1)
+	c.emitByte(left)
+	c.freeRegisters(1)
 }
 
-func (r Return) compile() {
+// emitNilReturn ends a chunk, so that falling off the end of a function or
+// script returns nil.
+func (c *compiler) emitNilReturn() {
+	register := c.allocRegister()
+	c.emitConstant(Nil, register)
+	c.emitOp(opReturn)
+	c.emitByte(register)
+	c.freeRegisters(1)
+}
 
+func (c *compiler) pushLoop(start int) {
+	c.loops = append(c.loops, &loopContext{start, []int{}})
 }
 
-func (b Break) compile() {
+func (c *compiler) popLoop() {
+	loop := c.loops[len(c.loops)-1]
+	c.loops = c.loops[:len(c.loops)-1]
+	for _, jump := range loop.breaks {
+		c.patchJump(jump)
+	}
+}
 
+func (c *compiler) compileFunction(name string, args []string, body []node, chunkType chunkType, line int) {
+	function := c.compileBody(name, args, body, chunkType, line)
+	register := c.allocRegister()
+	c.emitConstant(FunctionValue(function), register)
+	c.emitStoreVariable(name, register)
 }
 
-func (c Continue) compile() {
+// compileBody compiles a function in a chunk of its own.
+func (c *compiler) compileBody(name string, args []string, nodes []node, chunkType chunkType, line int) *AuroraFunction {
+	body := newCompiler(chunkType, c.warnings)
+	body.setLine(line)
+	for _, arg := range args {
+		body.declareLocal(arg)
+	}
+	body.compileBlock(nodes)
+	body.emitNilReturn()
+	c.setLine(line)
+	return &AuroraFunction{name, args, len(args), body.finish()}
+}
 
+func (t typeDecl) compile(c *compiler) {
+	c.setLine(t.Line)
+	base := c.allocRegister()
+	c.emitConstant(StringValue(t.Name), base)
+	for _, field := range t.Fields {
+		c.emitConstant(StringValue(field), c.allocRegister())
+	}
+	for _, method := range t.Methods {
+		args := append([]string{"self"}, method.Args...)
+		function := c.compileBody(t.Name+"."+method.Name, args, method.Body, typeFunction, method.Line)
+		c.emitConstant(FunctionValue(function), c.allocRegister())
+	}
+	c.emitOp(opType)
+	c.emitByte(base)
+	c.emitByte(byte(len(t.Fields)))
+	c.emitByte(byte(len(t.Methods)))
+	c.emitByte(base)
+	c.emitStoreVariable(t.Name, base)
 }
 
-func (f FuncCall) compile() {
+func (m methodCallExpr) compile(c *compiler) {
+	c.setLine(m.Line)
+	function := c.allocRegister()
+	m.Receiver.compile(c)
+	for _, arg := range m.Args {
+		arg.compile(c)
+	}
+	c.setLine(m.Line)
+	c.emitOp(opMethod)
+	c.emitByte(function + 1)
+	c.emitByte(c.makeConstant(StringValue(m.Name)))
+	c.emitByte(function)
+	c.emitOp(opCall)
+	c.emitByte(function)
+	c.emitByte(byte(len(m.Args) + 1))
+	c.emitByte(function + 1)
+	c.emitByte(function)
+	c.freeRegisters(len(m.Args) + 1)
+}
 
+func (i ifStmt) compile(c *compiler) {
+	branches := append([]elseIfClause{{i.Cond, i.Then, i.Line}}, i.ElseIfs...)
+	// every branch jumps straight past the whole chain when it is done
+	endJumps := make([]int, 0, len(branches))
+	for n, branch := range branches {
+		c.setLine(branch.Line)
+		branch.Cond.compile(c)
+		falseJump := c.emitJump(opJumpIfFalse, c.topRegister())
+		c.freeRegisters(1)
+		c.compileBlock(branch.Then)
+		if n < len(branches)-1 || len(i.Else) > 0 {
+			endJumps = append(endJumps, c.emitJump(opJump))
+		}
+		c.patchJump(falseJump)
+	}
+	c.compileBlock(i.Else)
+	for _, jump := range endJumps {
+		c.patchJump(jump)
+	}
 }
 
-func (a Assignment) compile() {
+func (w whileStmt) compile(c *compiler) {
+	c.setLine(w.Line)
+	loopStart := len(c.chunk.code)
+	w.Cond.compile(c)
+	exitJump := c.emitJump(opJumpIfFalse, c.topRegister())
+	c.freeRegisters(1)
+	c.pushLoop(loopStart)
+	c.compileBlock(w.Body)
+	c.emitLoop(loopStart)
+	c.patchJump(exitJump)
+	c.popLoop()
+}
 
+func (f forStmt) compile(c *compiler) {
+	c.setLine(f.Line)
+	f.In.compile(c)
+	list := c.topRegister()
+	index := c.allocRegister()
+	c.emitConstant(NumberValue(0), index)
+	value := c.allocRegister()
+	loopStart := len(c.chunk.code)
+	exitJump := c.emitJump(opIterate, list, index, value)
+	if len(f.Names) == 1 {
+		c.emitStoreVariable(f.Names[0], value)
+	} else {
+		c.emitUnpack(f.Names, value)
+	}
+	c.pushLoop(loopStart)
+	c.compileBlock(f.Body)
+	c.emitLoop(loopStart)
+	c.patchJump(exitJump)
+	c.popLoop()
+	c.freeRegisters(3)
 }
 
-func (a AssignIndex) compile() {
+func (f funcStmt) compile(c *compiler) {
+	c.compileFunction(f.Name, f.Args, f.Body, typeFunction, f.Line)
+}
 
+func (s subStmt) compile(c *compiler) {
+	c.compileFunction(s.Name, s.Args, s.Body, typeSubroutine, s.Line)
 }
 
-func (u Unary) compile() {
+func (r returnStmt) compile(c *compiler) {
+	c.setLine(r.Line)
+	if c.chunkType == typeProgram {
+		panic(fmt.Sprintf("'return' outside of a function at line %d", r.Line))
+	}
+	r.Expr.compile(c)
+	c.inlineFinally(0)
+	c.setLine(r.Line)
+	c.emitOp(opReturn)
+	c.emitByte(c.topRegister())
+}
 
+// inlineFinally compiles the pending finally blocks from the innermost one
+// out to the one at index outer. Control has left a finally block's try
+// statement, and every try statement inside it, by the time the block runs,
+// so none of their handlers cover the copy.
+func (c *compiler) inlineFinally(outer int) {
+	finallies := c.finallies
+	for i := len(finallies) - 1; i >= outer; i-- {
+		c.finallies = finallies[:i]
+		start := len(c.chunk.code)
+		c.compileBlock(finallies[i].body)
+		for _, try := range c.tries[finallies[i].try:] {
+			try.excluded = append(try.excluded, [2]int{start, len(c.chunk.code)})
+		}
+	}
+	c.finallies = finallies
 }
 
-func (b Binary) compile() {
+// loopFinally is the index of the outermost finally block inside the
+// innermost loop.
+func (c *compiler) loopFinally() int {
+	i := len(c.finallies)
+	for i > 0 && c.finallies[i-1].loops >= len(c.loops) {
+		i--
+	}
+	return i
+}
 
+func (b breakStmt) compile(c *compiler) {
+	c.setLine(b.Line)
+	if len(c.loops) == 0 {
+		panic(fmt.Sprintf("'break' outside of a loop at line %d", b.Line))
+	}
+	c.inlineFinally(c.loopFinally())
+	c.setLine(b.Line)
+	loop := c.loops[len(c.loops)-1]
+	loop.breaks = append(loop.breaks, c.emitJump(opJump))
 }
 
-func (n Number) compile() {
+func (k continueStmt) compile(c *compiler) {
+	c.setLine(k.Line)
+	if len(c.loops) == 0 {
+		panic(fmt.Sprintf("'continue' outside of a loop at line %d", k.Line))
+	}
+	c.inlineFinally(c.loopFinally())
+	c.setLine(k.Line)
+	c.emitLoop(c.loops[len(c.loops)-1].start)
+}
 
+// addHandler sends errors raised by the code from start to end, apart from
+// the excluded ranges in it, to the next instruction.
+func (c *compiler) addHandler(start, end int, register byte, excluded [][2]int) {
+	target := len(c.chunk.code)
+	for _, r := range excluded {
+		if r[0] >= end {
+			break
+		}
+		if r[0] > start {
+			c.chunk.handlers = append(c.chunk.handlers, handler{start, r[0], target, register})
+		}
+		if r[1] > start {
+			start = r[1]
+		}
+	}
+	if start < end {
+		c.chunk.handlers = append(c.chunk.handlers, handler{start, end, target, register})
+	}
 }
 
-func (s String) compile() {
+// tryStmt compiles to
+//
+//	body; jump done
+//	catch: store error; catch block; jump done
+//	finally: finally block; rethrow
+//	done: finally block
+//
+// with one handler sending errors in the body to catch, and another sending
+// errors in the body or catch block to finally. The finally block is also
+// copied before every break, continue or return that leaves the statement,
+// where neither handler covers it.
+func (t tryStmt) compile(c *compiler) {
+	c.setLine(t.Line)
+	register := c.allocRegister()
+	start := len(c.chunk.code)
+	try := &tryContext{}
+	c.tries = append(c.tries, try)
+	if t.Finally != nil {
+		c.finallies = append(c.finallies, finallyContext{t.Finally, len(c.loops), len(c.tries) - 1})
+	}
+	c.compileBlock(t.Body)
+	end := len(c.chunk.code)
+	c.setLine(t.Line)
+	exits := []int{c.emitJump(opJump)}
+	if t.Catch != nil {
+		c.addHandler(start, end, register, try.excluded)
+		if t.CatchName != "" {
+			c.emitStoreVariable(t.CatchName, register)
+		}
+		c.compileBlock(t.Catch)
+		c.setLine(t.Line)
+		exits = append(exits, c.emitJump(opJump))
+	}
+	if t.Finally != nil {
+		c.finallies = c.finallies[:len(c.finallies)-1]
+		c.addHandler(start, len(c.chunk.code), register, try.excluded)
+		c.compileBlock(t.Finally)
+		c.setLine(t.Line)
+		c.emitOp(opThrow)
+		c.emitByte(register)
+	}
+	c.tries = c.tries[:len(c.tries)-1]
+	for _, exit := range exits {
+		c.patchJump(exit)
+	}
+	c.compileBlock(t.Finally)
+}
 
+func (t throwStmt) compile(c *compiler) {
+	c.setLine(t.Line)
+	t.Expr.compile(c)
+	c.setLine(t.Line)
+	c.emitOp(opThrow)
+	c.emitByte(c.topRegister())
 }
 
-func (b Bool) compile() {
+func (f callExpr) compile(c *compiler) {
+	c.setLine(f.Line)
+	f.Func.compile(c)
+	base := c.topRegister()
+	for _, arg := range f.Args {
+		arg.compile(c)
+	}
+	c.setLine(f.Line)
+	c.emitOp(opCall)
+	c.emitByte(base)
+	c.emitByte(byte(len(f.Args)))
+	c.emitByte(base + 1)
+	c.emitByte(base)
+	c.freeRegisters(len(f.Args))
+}
 
+func (a assignStmt) compile(c *compiler) {
+	c.setLine(a.Line)
+	if a.Op == assign {
+		a.Right.compile(c)
+	} else {
+		c.emitLoadVariable(a.Left, c.allocRegister())
+		a.Right.compile(c)
+		c.emitBinary(compoundOps[a.Op])
+	}
+	c.emitStoreVariable(a.Left, c.topRegister())
 }
 
-func (l List) compile() {
+func (m multiAssignStmt) compile(c *compiler) {
+	c.setLine(m.Line)
+	if len(m.Right) == 1 {
+		m.Right[0].compile(c)
+		c.emitUnpack(m.Names, c.topRegister())
+		c.freeRegisters(1)
+		return
+	}
+	// every value is computed before any is stored, so a, b = b, a swaps
+	base := c.topRegister() + 1
+	for _, right := range m.Right {
+		right.compile(c)
+	}
+	for i, name := range m.Names {
+		c.emitStoreVariable(name, base+byte(i))
+	}
+	c.freeRegisters(len(m.Right))
+}
 
+// emitUnpack stores the items of the list in register into names, which
+// must be as many as there are items.
+func (c *compiler) emitUnpack(names []string, register byte) {
+	base := c.topRegister() + 1
+	for range names {
+		c.allocRegister()
+	}
+	c.emitOp(opUnpack)
+	c.emitByte(register)
+	c.emitByte(byte(len(names)))
+	c.emitByte(base)
+	for i, name := range names {
+		c.emitStoreVariable(name, base+byte(i))
+	}
+	c.freeRegisters(len(names))
+}
+
+func (m matchStmt) compile(c *compiler) {
+	c.setLine(m.Line)
+	m.Subject.compile(c)
+	subject := c.topRegister()
+	if isLiteralMatch(m) {
+		c.warn("match over literals has no default case")
+	}
+	endJumps := make([]int, 0, len(m.Cases))
+	for n, clause := range m.Cases {
+		c.setLine(clause.Line)
+		var fails []int
+		clause.Pattern.match(c, subject, &fails)
+		if clause.Guard != nil {
+			clause.Guard.compile(c)
+			fails = append(fails, c.emitJump(opJumpIfFalse, c.topRegister()))
+			c.freeRegisters(1)
+		}
+		c.compileBlock(clause.Body)
+		if n < len(m.Cases)-1 {
+			endJumps = append(endJumps, c.emitJump(opJump))
+		}
+		for _, jump := range fails {
+			c.patchJump(jump)
+		}
+	}
+	for _, jump := range endJumps {
+		c.patchJump(jump)
+	}
+	c.freeRegisters(1)
 }
 
-func (v Variable) compile() {
+// isLiteralMatch reports whether every case of m tests for a literal, so
+// that values the cases did not anticipate fall through silently.
+func isLiteralMatch(m matchStmt) bool {
+	for _, c := range m.Cases {
+		if _, ok := c.Pattern.(literalPattern); !ok {
+			return false
+		}
+	}
+	return len(m.Cases) > 0
+}
 
+func (l literalPattern) match(c *compiler, register byte, fails *[]int) {
+	value := c.allocRegister()
+	c.emitConstant(l.Value, value)
+	*fails = append(*fails, c.emitJump(opJumpIfNotEqual, register, value))
+	c.freeRegisters(1)
 }
 
-func (i Index) compile() {
+func (wildcardPattern) match(c *compiler, register byte, fails *[]int) {}
+
+func (b bindPattern) match(c *compiler, register byte, fails *[]int) {
+	c.emitStoreVariable(b.Name, register)
+}
 
+func (l listPattern) match(c *compiler, register byte, fails *[]int) {
+	*fails = append(*fails, c.emitJump(opJumpIfNotKind, register, byte(ListKind)))
+	atLeast := byte(0)
+	if l.HasRest {
+		atLeast = 1
+	}
+	*fails = append(*fails, c.emitJump(opJumpIfNotLen, register, byte(len(l.Items)), atLeast))
+	for i, item := range l.Items {
+		if _, ok := item.(wildcardPattern); ok {
+			continue
+		}
+		value := c.allocRegister()
+		c.emitConstant(NumberValue(float64(i)), c.allocRegister())
+		c.emitOp(opIndex)
+		c.emitByte(register)
+		c.emitByte(value + 1)
+		c.emitByte(value)
+		c.freeRegisters(1)
+		item.match(c, value, fails)
+		c.freeRegisters(1)
+	}
+	if l.Rest != "" {
+		rest := c.allocRegister()
+		c.emitConstant(NumberValue(float64(len(l.Items))), c.allocRegister())
+		c.emitConstant(Nil, c.allocRegister())
+		c.emitOp(opSlice)
+		c.emitByte(register)
+		c.emitByte(rest + 1)
+		c.emitByte(rest + 2)
+		c.emitByte(rest)
+		c.freeRegisters(2)
+		c.emitStoreVariable(l.Rest, rest)
+		c.freeRegisters(1)
+	}
+}
+
+func (m mapPattern) match(c *compiler, register byte, fails *[]int) {
+	*fails = append(*fails, c.emitJump(opJumpIfNotKind, register, byte(MapKind)))
+	for i, key := range m.Keys {
+		value := c.allocRegister()
+		c.emitConstant(StringValue(key), c.allocRegister())
+		*fails = append(*fails, c.emitJump(opJumpIfNoKey, register, value+1))
+		c.emitOp(opIndex)
+		c.emitByte(register)
+		c.emitByte(value + 1)
+		c.emitByte(value)
+		c.freeRegisters(1)
+		m.Values[i].match(c, value, fails)
+		c.freeRegisters(1)
+	}
+}
+
+func (a assignIndexStmt) compile(c *compiler) {
+	c.setLine(a.Line)
+	a.Left.compile(c)
+	target := c.topRegister()
+	a.Index.compile(c)
+	if a.Op == assign {
+		a.Right.compile(c)
+	} else {
+		value := c.allocRegister()
+		c.emitOp(opIndex)
+		c.emitByte(target)
+		c.emitByte(target + 1)
+		c.emitByte(value)
+		a.Right.compile(c)
+		c.emitBinary(compoundOps[a.Op])
+	}
+	c.emitOp(opIndexAssign)
+	c.emitByte(target)
+	c.emitByte(target + 1)
+	c.emitByte(target + 2)
+}
+
+func (u unaryExpr) compile(c *compiler) {
+	c.setLine(u.Line)
+	u.Expr.compile(c)
+	register := c.topRegister()
+	switch u.Op {
+	case minus:
+		c.emitOp(opNeg)
+	case not:
+		c.emitOp(opNot)
+	}
+	c.emitByte(register)
+	c.emitByte(register)
+}
+
+func (b binaryExpr) compile(c *compiler) {
+	c.setLine(b.Line)
+	switch b.Op {
+	case and, or:
+		b.Left.compile(c)
+		op := opJumpIfFalse
+		if b.Op == or {
+			op = opJumpIfTrue
+		}
+		jump := c.emitJump(op, c.topRegister())
+		c.freeRegisters(1)
+		b.Right.compile(c)
+		c.patchJump(jump)
+	default:
+		b.Left.compile(c)
+		b.Right.compile(c)
+		c.setLine(b.Line)
+		c.emitBinary(b.Op)
+	}
+}
+
+func (n numberLit) compile(c *compiler) {
+	c.setLine(n.Line)
+	c.emitConstant(NumberValue(n.Value), c.allocRegister())
+}
+
+func (s stringLit) compile(c *compiler) {
+	c.setLine(s.Line)
+	c.emitConstant(StringValue(s.Value), c.allocRegister())
+}
+
+func (b boolLit) compile(c *compiler) {
+	c.setLine(b.Line)
+	c.emitConstant(BoolValue(b.Value), c.allocRegister())
+}
+
+func (l listLit) compile(c *compiler) {
+	c.setLine(l.Line)
+	dest := c.allocRegister()
+	for _, value := range l.Values {
+		value.compile(c)
+	}
+	c.setLine(l.Line)
+	c.emitOp(opList)
+	c.emitByte(dest + 1)
+	c.emitByte(byte(len(l.Values)))
+	c.emitByte(dest)
+	c.freeRegisters(len(l.Values))
+}
+
+func (v variableExpr) compile(c *compiler) {
+	c.setLine(v.Line)
+	c.emitLoadVariable(v.Name, c.allocRegister())
+}
+
+func (i indexExpr) compile(c *compiler) {
+	c.setLine(i.Line)
+	i.Expr.compile(c)
+	i.Index.compile(c)
+	c.setLine(i.Line)
+	register := byte(c.registers - 2)
+	c.emitOp(opIndex)
+	c.emitByte(register)
+	c.emitByte(register + 1)
+	c.emitByte(register)
+	c.freeRegisters(1)
+}
+
+func (s sliceExpr) compile(c *compiler) {
+	c.setLine(s.Line)
+	s.Expr.compile(c)
+	register := c.topRegister()
+	for _, bound := range []node{s.Start, s.End} {
+		if bound == nil {
+			c.emitConstant(Nil, c.allocRegister())
+		} else {
+			bound.compile(c)
+		}
+	}
+	c.setLine(s.Line)
+	c.emitOp(opSlice)
+	c.emitByte(register)
+	c.emitByte(register + 1)
+	c.emitByte(register + 2)
+	c.emitByte(register)
+	c.freeRegisters(2)
 }
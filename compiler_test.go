@@ -0,0 +1,299 @@
+package aurora
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestCompileConcurrently(t *testing.T) {
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			// every program gets its own warning, and only that one
+			src := fmt.Sprintf("fn f x\n  match x\n  case %d -> return 1\n  end\nend\n", i)
+			for j := 0; j < 100; j++ {
+				program, err := Compile(src)
+				if err != nil {
+					t.Error(err)
+					return
+				}
+				if warnings := program.Warnings(); len(warnings) != 1 {
+					t.Errorf("got warnings %q, want one", warnings)
+					return
+				}
+			}
+		}(i)
+	}
+	wg.Wait()
+}
+
+// finallyCases run scripts whose finally blocks throw as control leaves
+// their try statements. Each finally must run once, and its error must go
+// to the statements around the try rather than to the try's own handlers.
+var finallyCases = []struct {
+	name, src, out, err string
+}{
+	{"return from body", `fn f
+  try
+    return 1
+  catch e
+    print "caught", e
+  finally
+    print "finally"
+    throw "boom"
+  end
+end
+x = f()
+`, "finally\n", "boom"},
+	{"return from catch", `fn f
+  try
+    throw "first"
+  catch e
+    print "caught", e
+    return 1
+  finally
+    print "finally"
+    throw "boom"
+  end
+end
+x = f()
+`, "caught first\nfinally\n", "boom"},
+	{"break", `try
+  for i, {1, 2}
+    try
+      break
+    catch e
+      print "inner", e
+    finally
+      print "finally"
+      throw "boom"
+    end
+  end
+catch e
+  print "outer", e
+end
+`, "finally\nouter boom\n", ""},
+	{"nested try", `fn f
+  try
+    try
+      return 1
+    catch e
+      print "inner", e
+    end
+  finally
+    print "finally"
+    throw "boom"
+  end
+end
+try
+  x = f()
+catch e
+  print "outer", e
+end
+`, "finally\nouter boom\n", ""},
+	{"finally returns normally", `fn f
+  try
+    return 1
+  catch e
+    print "caught", e
+  finally
+    print "finally"
+  end
+end
+print f()
+`, "finally\n1\n", ""},
+}
+
+func TestFinallyRunsOnceAsControlLeaves(t *testing.T) {
+	for _, tc := range finallyCases {
+		var out bytes.Buffer
+		_, err := run(t, Options{Stdout: &out}, tc.src)
+		if out.String() != tc.out {
+			t.Errorf("%s: printed %q, want %q", tc.name, out.String(), tc.out)
+		}
+		switch {
+		case tc.err == "" && err != nil:
+			t.Errorf("%s: %v", tc.name, err)
+		case tc.err != "" && (err == nil || !strings.Contains(err.Error(), tc.err)):
+			t.Errorf("%s: got %v, want %s", tc.name, err, tc.err)
+		}
+	}
+}
+
+func TestAssignToIndexChains(t *testing.T) {
+	runScripts(t, Options{}, []scriptCase{
+		{"grid", `grid = {{0, 0}, {0, 0}}
+y = 1
+grid:y:0 = 5
+grid:0:2 = 7
+print grid`, "{{0, 0, 7}, {5, 0}}\n"},
+		{"maps and fields", `obj = json_parse("{}")
+obj:"a" = {1}
+obj:"a":0 = 2
+obj.b = obj.a
+obj.b:1 = 3
+print obj`, `{"a": {2, 3}, "b": {2, 3}}` + "\n"},
+		{"through a call", `grid = {{0}}
+fn row -> grid:0
+x = row()
+x:0 = 9
+print grid`, "{{9}}\n"},
+	})
+	runScriptErrors(t, Options{}, []scriptCase{
+		{"out of range", "xs = {{1}}\nxs:0:5 = 1", "line 2: list index 5 out of range for length 1"},
+		{"into a string", "s = {\"ab\"}\ns:0:0 = \"x\"", "line 2: strings are immutable"},
+		{"through a number", "m = 1\nm:0:0 = 1", "line 2: cannot index a number"},
+		{"into a number", "xs = {1}\nxs:0:0 = 2", "line 2: cannot assign to an index of a number"},
+	})
+}
+
+func TestMultipleAssignment(t *testing.T) {
+	runScripts(t, Options{}, []scriptCase{
+		{"swap", `a, b = 1, 2
+a, b = b, a
+print a, b`, "2 1\n"},
+		{"right side is evaluated first", `a, b = 1, 2
+a, b = a + b, a
+print a, b`, "3 1\n"},
+		{"destructure a list", `fn divmod a, b -> {int(a / b), a - b * int(a / b)}
+q, r = divmod(17, 5)
+print q, r`, "3 2\n"},
+		{"destructure in a for loop", `for k, v, {{1, "a"}, {2, "b"}}
+  print k, v
+end`, "1 a\n2 b\n"},
+	})
+	runScriptErrors(t, Options{}, []scriptCase{
+		{"too few items", `a, b = {1}`, "cannot unpack 1 values into 2 names"},
+		{"not a list", `a, b = 5`, "cannot unpack a number"},
+		{"loop item too short", "for a, b, {{1, 2}, {1}}\nend", "cannot unpack 1 values into 2 names"},
+	})
+	if _, err := Compile("a, b = 1, 2, 3\n"); err == nil || !strings.Contains(err.Error(), "Cannot assign 3 values to 2 names") {
+		t.Errorf("got %v, want an arity error", err)
+	}
+}
+
+func TestElseIfChains(t *testing.T) {
+	runScripts(t, Options{}, []scriptCase{
+		{"else if and elif", `fn grade n
+  if n >= 90
+    return "A"
+  else if n >= 80
+    return "B"
+  elif n >= 70
+    return "C"
+  else
+    return "F"
+  end
+end
+print grade(95), grade(85), grade(75), grade(10)`, "A B C F\n"},
+		{"no else", `x = 3
+if x == 1
+  print "one"
+elif x == 2
+  print "two"
+end
+print "done"`, "done\n"},
+		{"later conditions are not evaluated", `fn check n
+  print "checked", n
+  return true
+end
+if check(1)
+  x = 1
+elif check(2)
+  x = 2
+end`, "checked 1\n"},
+		{"nested else if still works", `x = 2
+if x == 1
+  print "one"
+else
+  if x == 2
+    print "two"
+  end
+end`, "two\n"},
+	})
+}
+
+func TestLongElifChain(t *testing.T) {
+	var src strings.Builder
+	src.WriteString("fn pick n\n  if n == 0\n    return 0\n")
+	for i := 1; i < 200; i++ {
+		fmt.Fprintf(&src, "  elif n == %d\n    return n * n\n", i)
+	}
+	src.WriteString("  end\n  return -1\nend\n")
+	vm, err := run(t, Options{}, src.String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	for n, want := range map[int]int{0: 0, 1: 1, 150: 22500, 199: 39601, 200: -1} {
+		got, err := vm.Call("pick", n)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if int(got.AsNumber()) != want {
+			t.Errorf("pick(%d) = %v, want %d", n, got, want)
+		}
+	}
+}
+
+func TestMatch(t *testing.T) {
+	const describe = `fn describe v
+  match v
+  case 0 -> return "zero"
+  case "hi" -> return "greeting"
+  case true -> return "yes"
+  case {} -> return "empty"
+  case {x} -> return "one " + str(x)
+  case {first, ...rest} if first > 100 -> return "big " + str(len(rest))
+  case {first, ...rest} -> return "list " + str(first) + " " + str(rest)
+  case {"name": n} -> return "named " + n
+  case n if type(n) == "number" -> return "number " + str(n)
+  case _ -> return "other"
+  end
+end
+`
+	runScripts(t, Options{}, []scriptCase{
+		{"literals", describe + `print describe(0), describe("hi"), describe(true)`, "zero greeting yes\n"},
+		{"lists", describe + `print describe({}), describe({7}), describe({101, 2, 3}), describe({1, 2, 3})`, "empty one 7 big 2 list 1 {2, 3}\n"},
+		{"maps", describe + `m = json_parse("{}")
+m.name = "bo"
+m.age = 3
+print describe(m)`, "named bo\n"},
+		{"bindings, guards and wildcards", describe + `print describe(5), describe("x"), describe(false)`, "number 5 other other\n"},
+		{"no case matches", `match 3
+case 1 -> print "one"
+case {x} -> print x
+end
+print "after"`, "after\n"},
+		{"block bodies", `match {1, 2}
+case {a, b}
+  s = a + b
+  print s
+end`, "3\n"},
+		{"rest without a name", `match {1, 2, 3}
+case {1, ...} -> print "starts with 1"
+end`, "starts with 1\n"},
+		{"the regex function still works", `print match("a+", "caat")`, "true\n"},
+	})
+}
+
+func TestMatchWarnings(t *testing.T) {
+	for src, want := range map[string]int{
+		"match 1\ncase 1 -> print 1\ncase 2 -> print 2\nend\n": 1,
+		"match 1\ncase 1 -> print 1\ncase _ -> print 2\nend\n": 0,
+		"match 1\ncase 1 -> print 1\ncase n -> print n\nend\n": 0,
+		"match 1\ncase {x} -> print x\nend\n":                  0,
+	} {
+		program, err := Compile(src)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := len(program.Warnings()); got != want {
+			t.Errorf("%q: got warnings %q, want %d", src, program.Warnings(), want)
+		}
+	}
+}
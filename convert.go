@@ -0,0 +1,430 @@
+package aurora
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"reflect"
+	"regexp"
+	"strings"
+)
+
+// DefaultFieldTag is the struct tag consulted for field names when Options
+// leaves FieldTag empty. A tag of "-" skips the field; fields without a tag
+// keep their Go name.
+const DefaultFieldTag = "aurora"
+
+var (
+	valueType  = reflect.TypeOf(Value{})
+	regexpType = reflect.TypeOf((*regexp.Regexp)(nil))
+	errorType  = reflect.TypeOf((*error)(nil)).Elem()
+)
+
+// converter marshals between Go and Aurora values using reflection.
+type converter struct {
+	tag string
+}
+
+var defaultConverter = converter{DefaultFieldTag}
+
+// ToValue converts a Go value to an Aurora value. Numbers, strings and
+// booleans map to their Aurora counterparts, slices and arrays to lists,
+// string-keyed maps and structs to maps, and funcs to native functions.
+// Pointers and interfaces are followed; nil ones become nil.
+func ToValue(v any) (Value, error) {
+	return defaultConverter.toValue(reflect.ValueOf(v))
+}
+
+// FromValue stores v into the Go value that out points to, converting it to
+// out's type. Structs are filled from maps or records, field by field.
+// Aurora functions can only be converted by a VM, see Get.
+func FromValue(v Value, out any) error {
+	return defaultConverter.store(nil, v, out)
+}
+
+// Get converts the global name into the Go value that out points to.
+func (vm *AuroraVM) Get(name string, out any) error {
+	value, ok := vm.globals[name]
+	if !ok {
+		return fmt.Errorf("undefined global '%s'", name)
+	}
+	return vm.converter.store(vm, value, out)
+}
+
+func (c converter) store(vm *AuroraVM, v Value, out any) error {
+	rv := reflect.ValueOf(out)
+	if rv.Kind() != reflect.Pointer || rv.IsNil() {
+		return fmt.Errorf("cannot store into %T: need a non-nil pointer", out)
+	}
+	return c.fromValue(vm, v, rv.Elem())
+}
+
+// fieldName returns the map key for a struct field, or false if the field is
+// not converted.
+func (c converter) fieldName(field reflect.StructField) (string, bool) {
+	if !field.IsExported() {
+		return "", false
+	}
+	tag, ok := field.Tag.Lookup(c.tag)
+	if !ok {
+		return field.Name, true
+	}
+	name, _, _ := strings.Cut(tag, ",")
+	switch name {
+	case "-":
+		return "", false
+	case "":
+		return field.Name, true
+	}
+	return name, true
+}
+
+func (c converter) toValue(rv reflect.Value) (Value, error) {
+	if !rv.IsValid() {
+		return Nil, nil
+	}
+	if rv.Type() == valueType {
+		return rv.Interface().(Value), nil
+	}
+	if rv.Type() == regexpType && !rv.IsNil() {
+		return RegexValue(rv.Interface().(*regexp.Regexp)), nil
+	}
+	switch rv.Kind() {
+	case reflect.Pointer, reflect.Interface:
+		if rv.IsNil() {
+			return Nil, nil
+		}
+		return c.toValue(rv.Elem())
+	case reflect.Bool:
+		return BoolValue(rv.Bool()), nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return NumberValue(float64(rv.Int())), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return NumberValue(float64(rv.Uint())), nil
+	case reflect.Float32, reflect.Float64:
+		return NumberValue(rv.Float()), nil
+	case reflect.String:
+		return StringValue(rv.String()), nil
+	case reflect.Slice, reflect.Array:
+		items := make([]Value, rv.Len())
+		for i := range items {
+			item, err := c.toValue(rv.Index(i))
+			if err != nil {
+				return Nil, fmt.Errorf("index %d: %w", i, err)
+			}
+			items[i] = item
+		}
+		return ListValue(items), nil
+	case reflect.Map:
+		if rv.Type().Key().Kind() != reflect.String {
+			return Nil, fmt.Errorf("cannot convert %s to an Aurora value: map keys must be strings", rv.Type())
+		}
+		items := make(map[string]Value, rv.Len())
+		iter := rv.MapRange()
+		for iter.Next() {
+			key := iter.Key().String()
+			item, err := c.toValue(iter.Value())
+			if err != nil {
+				return Nil, fmt.Errorf("key %q: %w", key, err)
+			}
+			items[key] = item
+		}
+		return MapValue(items), nil
+	case reflect.Struct:
+		items := map[string]Value{}
+		for i := 0; i < rv.NumField(); i++ {
+			name, ok := c.fieldName(rv.Type().Field(i))
+			if !ok {
+				continue
+			}
+			item, err := c.toValue(rv.Field(i))
+			if err != nil {
+				return Nil, fmt.Errorf("field %s: %w", rv.Type().Field(i).Name, err)
+			}
+			items[name] = item
+		}
+		return MapValue(items), nil
+	case reflect.Func:
+		if rv.IsNil() {
+			return Nil, nil
+		}
+		return c.wrapFunc(rv), nil
+	}
+	return Nil, fmt.Errorf("cannot convert %s to an Aurora value", rv.Type())
+}
+
+// wrapFunc turns a Go func into a native function that converts its
+// arguments and results. A trailing error result, or a panic, becomes a
+// runtime error. Several results are returned as a list of them.
+func (c converter) wrapFunc(fn reflect.Value) Value {
+	typ := fn.Type()
+	arity := typ.NumIn()
+	if typ.IsVariadic() {
+		arity = Variadic
+	}
+	returnsError := typ.NumOut() > 0 && typ.Out(typ.NumOut()-1) == errorType
+	return NativeValue(&NativeFunction{typ.String(), arity, func(vm *AuroraVM, args []Value) (result Value, err error) {
+		if typ.IsVariadic() && len(args) < typ.NumIn()-1 {
+			return Nil, fmt.Errorf("expects at least %d arguments, got %d", typ.NumIn()-1, len(args))
+		}
+		in := make([]reflect.Value, len(args))
+		for i, arg := range args {
+			var param reflect.Type
+			if typ.IsVariadic() && i >= typ.NumIn()-1 {
+				param = typ.In(typ.NumIn() - 1).Elem()
+			} else {
+				param = typ.In(i)
+			}
+			in[i] = reflect.New(param).Elem()
+			if err := c.fromValue(vm, arg, in[i]); err != nil {
+				return Nil, fmt.Errorf("argument %d: %w", i+1, err)
+			}
+		}
+		defer func() {
+			if r := recover(); r != nil {
+				// a script the func called back into failed; let it unwind
+				if runtimeErr, ok := r.(*RuntimeError); ok {
+					panic(runtimeErr)
+				}
+				result, err = Nil, fmt.Errorf("panic: %v", r)
+			}
+		}()
+		out := fn.Call(in)
+		if returnsError {
+			if err := out[len(out)-1]; !err.IsNil() {
+				return Nil, err.Interface().(error)
+			}
+			out = out[:len(out)-1]
+		}
+		switch len(out) {
+		case 0:
+			return Nil, nil
+		case 1:
+			return c.toValue(out[0])
+		}
+		items := make([]Value, len(out))
+		for i, result := range out {
+			if items[i], err = c.toValue(result); err != nil {
+				return Nil, fmt.Errorf("result %d: %w", i+1, err)
+			}
+		}
+		return ListValue(items), nil
+	}})
+}
+
+var errNeedVM = errors.New("Aurora functions can only be converted by a VM")
+
+func (c converter) mismatch(v Value, typ reflect.Type) error {
+	return fmt.Errorf("cannot convert Aurora %s to Go %s", v.kind, typ)
+}
+
+// fromValue converts v into rv, which must be settable.
+func (c converter) fromValue(vm *AuroraVM, v Value, rv reflect.Value) error {
+	typ := rv.Type()
+	if typ == valueType {
+		rv.Set(reflect.ValueOf(v))
+		return nil
+	}
+	if typ == regexpType && v.kind == RegexKind {
+		rv.Set(reflect.ValueOf(v.AsRegex()))
+		return nil
+	}
+	switch typ.Kind() {
+	case reflect.Interface:
+		if typ.NumMethod() != 0 {
+			return c.mismatch(v, typ)
+		}
+		var export any
+		if err := protect(func() { export = v.Export() }); err != nil {
+			return err
+		}
+		if export != nil {
+			rv.Set(reflect.ValueOf(export))
+		} else {
+			rv.Set(reflect.Zero(typ))
+		}
+		return nil
+	case reflect.Pointer:
+		if v.kind == NilKind {
+			rv.Set(reflect.Zero(typ))
+			return nil
+		}
+		ptr := reflect.New(typ.Elem())
+		if err := c.fromValue(vm, v, ptr.Elem()); err != nil {
+			return err
+		}
+		rv.Set(ptr)
+		return nil
+	case reflect.Bool:
+		if v.kind != BoolKind {
+			return c.mismatch(v, typ)
+		}
+		rv.SetBool(v.AsBool())
+		return nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		if v.kind != NumberKind {
+			return c.mismatch(v, typ)
+		}
+		n := v.number
+		// range-check in float64 first, as converting an out-of-range float
+		// to an integer does not fail but gives an unspecified value
+		if n != math.Trunc(n) || n < -1<<63 || n >= 1<<63 || rv.OverflowInt(int64(n)) {
+			return fmt.Errorf("cannot convert %s to Go %s", v, typ)
+		}
+		rv.SetInt(int64(n))
+		return nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		if v.kind != NumberKind {
+			return c.mismatch(v, typ)
+		}
+		n := v.number
+		if n != math.Trunc(n) || n < 0 || n >= 1<<64 || rv.OverflowUint(uint64(n)) {
+			return fmt.Errorf("cannot convert %s to Go %s", v, typ)
+		}
+		rv.SetUint(uint64(n))
+		return nil
+	case reflect.Float32, reflect.Float64:
+		if v.kind != NumberKind {
+			return c.mismatch(v, typ)
+		}
+		rv.SetFloat(v.number)
+		return nil
+	case reflect.String:
+		if v.kind != StringKind {
+			return c.mismatch(v, typ)
+		}
+		rv.SetString(v.AsString())
+		return nil
+	case reflect.Slice:
+		if v.kind != ListKind {
+			return c.mismatch(v, typ)
+		}
+		items := v.AsList().items
+		slice := reflect.MakeSlice(typ, len(items), len(items))
+		for i, item := range items {
+			if err := c.fromValue(vm, item, slice.Index(i)); err != nil {
+				return fmt.Errorf("index %d: %w", i, err)
+			}
+		}
+		rv.Set(slice)
+		return nil
+	case reflect.Array:
+		if v.kind != ListKind {
+			return c.mismatch(v, typ)
+		}
+		items := v.AsList().items
+		if len(items) != typ.Len() {
+			return fmt.Errorf("cannot convert list of %d items to Go %s", len(items), typ)
+		}
+		for i, item := range items {
+			if err := c.fromValue(vm, item, rv.Index(i)); err != nil {
+				return fmt.Errorf("index %d: %w", i, err)
+			}
+		}
+		return nil
+	case reflect.Map:
+		if v.kind != MapKind || typ.Key().Kind() != reflect.String {
+			return c.mismatch(v, typ)
+		}
+		m := reflect.MakeMapWithSize(typ, len(v.AsMap().items))
+		for key, item := range v.AsMap().items {
+			elem := reflect.New(typ.Elem()).Elem()
+			if err := c.fromValue(vm, item, elem); err != nil {
+				return fmt.Errorf("key %q: %w", key, err)
+			}
+			m.SetMapIndex(reflect.ValueOf(key).Convert(typ.Key()), elem)
+		}
+		rv.Set(m)
+		return nil
+	case reflect.Struct:
+		// a map or a record fills the fields named by its keys or fields
+		var lookup func(name string) (Value, bool)
+		switch v.kind {
+		case MapKind:
+			items := v.AsMap().items
+			lookup = func(name string) (Value, bool) {
+				item, ok := items[name]
+				return item, ok
+			}
+		case RecordKind:
+			record := v.AsRecord()
+			lookup = func(name string) (Value, bool) {
+				if i := record.typ.field(name); i >= 0 {
+					return record.fields[i], true
+				}
+				return Nil, false
+			}
+		default:
+			return c.mismatch(v, typ)
+		}
+		for i := 0; i < typ.NumField(); i++ {
+			name, ok := c.fieldName(typ.Field(i))
+			if !ok {
+				continue
+			}
+			item, ok := lookup(name)
+			if !ok {
+				continue
+			}
+			if err := c.fromValue(vm, item, rv.Field(i)); err != nil {
+				return fmt.Errorf("field %s: %w", typ.Field(i).Name, err)
+			}
+		}
+		return nil
+	case reflect.Func:
+		if v.kind == NilKind {
+			rv.Set(reflect.Zero(typ))
+			return nil
+		}
+		if v.kind != FunctionKind && v.kind != NativeKind {
+			return c.mismatch(v, typ)
+		}
+		if vm == nil {
+			return errNeedVM
+		}
+		rv.Set(c.makeFunc(vm, v, typ))
+		return nil
+	}
+	return c.mismatch(v, typ)
+}
+
+// makeFunc builds a Go func of type typ that calls fn on vm. If typ's last
+// result is an error, failures are returned there; otherwise they panic.
+func (c converter) makeFunc(vm *AuroraVM, fn Value, typ reflect.Type) reflect.Value {
+	returnsError := typ.NumOut() > 0 && typ.Out(typ.NumOut()-1) == errorType
+	results := typ.NumOut()
+	if returnsError {
+		results--
+	}
+	return reflect.MakeFunc(typ, func(in []reflect.Value) []reflect.Value {
+		out := make([]reflect.Value, typ.NumOut())
+		for i := range out {
+			out[i] = reflect.New(typ.Out(i)).Elem()
+		}
+		fail := func(err error) []reflect.Value {
+			if !returnsError {
+				panic(err)
+			}
+			out[len(out)-1] = reflect.ValueOf(&err).Elem()
+			return out
+		}
+		args := make([]Value, len(in))
+		for i, arg := range in {
+			value, err := c.toValue(arg)
+			if err != nil {
+				return fail(fmt.Errorf("argument %d: %w", i+1, err))
+			}
+			args[i] = value
+		}
+		result, err := vm.CallValue(fn, args...)
+		if err != nil {
+			return fail(err)
+		}
+		if results > 0 {
+			if err := c.fromValue(vm, result, out[0]); err != nil {
+				return fail(err)
+			}
+		}
+		return out
+	})
+}
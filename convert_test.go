@@ -0,0 +1,129 @@
+package aurora
+
+import (
+	"errors"
+	"math"
+	"strings"
+	"testing"
+)
+
+func TestGetRejectsOutOfRangeIntegers(t *testing.T) {
+	vm := NewVM(Options{})
+	bad := []float64{math.Inf(1), math.Inf(-1), math.NaN(), 1.5}
+	for _, n := range append(bad, 1e19, -1e19, 1<<63) {
+		vm.Set("n", n)
+		var i int64
+		if err := vm.Get("n", &i); err == nil {
+			t.Errorf("Get(%v) into int64 = %d, want an error", n, i)
+		}
+	}
+	for _, n := range append(bad, 1e20, 1<<64, -1) {
+		vm.Set("n", n)
+		var u uint64
+		if err := vm.Get("n", &u); err == nil {
+			t.Errorf("Get(%v) into uint64 = %d, want an error", n, u)
+		}
+	}
+}
+
+func TestGetIntegers(t *testing.T) {
+	vm := NewVM(Options{})
+	vm.Set("n", -1<<63)
+	var i int64
+	if err := vm.Get("n", &i); err != nil || i != math.MinInt64 {
+		t.Errorf("got %d, %v; want MinInt64", i, err)
+	}
+	vm.Set("n", uint64(1<<63))
+	var u uint64
+	if err := vm.Get("n", &u); err != nil || u != 1<<63 {
+		t.Errorf("got %d, %v; want 1<<63", u, err)
+	}
+}
+
+func TestGetRecordIntoStruct(t *testing.T) {
+	vm, err := run(t, Options{}, `type Point x, y
+end
+p = Point(3, 4)
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var p struct {
+		X    float64 `aurora:"x"`
+		Y    int     `aurora:"y"`
+		Name string  `aurora:"name"`
+	}
+	if err := vm.Get("p", &p); err != nil {
+		t.Fatal(err)
+	}
+	if p.X != 3 || p.Y != 4 || p.Name != "" {
+		t.Errorf("got %+v, want x 3 and y 4", p)
+	}
+}
+
+func TestSetFuncWithSeveralResults(t *testing.T) {
+	vm := NewVM(Options{})
+	vm.Set("divmod", func(a, b int) (int, int, error) {
+		if b == 0 {
+			return 0, 0, errors.New("division by zero")
+		}
+		return a / b, a % b, nil
+	})
+	program, err := Compile("q, r = divmod(17, 5)\ny = divmod(1, 0)\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = vm.Run(program)
+	if err == nil || !strings.Contains(err.Error(), "division by zero") {
+		t.Errorf("got %v, want division by zero", err)
+	}
+	var q, r int
+	vm.Get("q", &q)
+	vm.Get("r", &r)
+	if q != 3 || r != 2 {
+		t.Errorf("divmod(17, 5) = %d, %d; want 3, 2", q, r)
+	}
+}
+
+func TestSetFuncPanicsBecomeRuntimeErrors(t *testing.T) {
+	vm := NewVM(Options{})
+	vm.Set("first", func(xs []int) int { return xs[0] })
+	program, err := Compile(`try
+  x = first({})
+catch e
+  message = e.message
+end
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := vm.Run(program); err != nil {
+		t.Fatal(err)
+	}
+	var message string
+	if vm.Get("message", &message); !strings.Contains(message, "panic: runtime error: index out of range") {
+		t.Errorf("caught %q", message)
+	}
+}
+
+func TestSetFuncCallingBackPassesScriptErrors(t *testing.T) {
+	vm := NewVM(Options{})
+	vm.Set("each", func(xs []int, fn func(int)) {
+		for _, x := range xs {
+			fn(x)
+		}
+	})
+	_, err := vm.Call("each", []int{1, 2}, func(int) {})
+	if err != nil {
+		t.Fatal(err)
+	}
+	program, err := Compile("fn boom x\n  throw \"boom\"\nend\nx = each({1}, boom)\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = vm.Run(program)
+	var runtimeErr *RuntimeError
+	if !errors.As(err, &runtimeErr) || runtimeErr.Message != "boom" {
+		t.Errorf("got %#v, want the script's boom", err)
+	}
+}
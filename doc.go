@@ -0,0 +1,91 @@
+// Package aurora embeds the Aurora scripting language in Go programs.
+//
+// A script is compiled once into a Program and run on a VM. The VM keeps the
+// globals a program defines, so the host can call script functions after the
+// top level has run:
+//
+//	program, err := aurora.Compile("fn area w, h -> w * h\n")
+//	if err != nil {
+//		return err
+//	}
+//	vm := aurora.NewVM(aurora.Options{})
+//	if err := vm.Run(program); err != nil {
+//		return err
+//	}
+//	area, err := vm.Call("area", 3, 4) // area.AsNumber() == 12
+//
+// Programs can be precompiled with WriteBytecode and loaded without parsing
+// by ReadBytecode. The aurora command in cmd/aurora does both.
+//
+// # Indexing
+//
+// xs:i reads item i of a list, character i of a string or the value under
+// key i of a map. List and string indices must be integers; negative ones
+// count back from the end, so xs:-1 is the last item, and an index out of
+// range is a runtime error. A map has nil under keys it does not have.
+// The index is everything up to the end of the expression, so xs:i + 1 is
+// the item after xs:i, and (xs:i) + 1 adds one to an item. A colon or dot
+// outside brackets starts another index: grid:y:x is item x of grid:y, and
+// ps:0.x is field x of ps:0.
+//
+// xs:i..j is a new list or string of the items from i up to, but not
+// including, j. Either bound may be left out, as in xs:1.. or xs:..-1, and
+// bounds past either end are clamped rather than being errors.
+//
+// xs:i = v replaces an item of a list, or appends v when i is the list's
+// length. It sets key i of a map, adding it if it is missing. Strings are
+// immutable, so assigning to an index of one is an error.
+//
+// # Records
+//
+// A type declaration names a record type, its fields and its methods:
+//
+//	type Point x, y
+//		fn norm -> sqrt(self.x * self.x + self.y * self.y)
+//		fn move dx, dy
+//			self.x = self.x + dx
+//			self.y = self.y + dy
+//		end
+//	end
+//
+// Calling the type with a value for each field makes a record, which prints
+// as Point(x: 3, y: 4). p.x and p:"x" both read a field, and assigning to
+// them changes it; records cannot gain fields their type does not declare.
+// p.move(1, 1) calls a method with p as self. Records are shared by
+// reference like lists, and two records are equal when they are of the same
+// type and their fields are equal. type(p) is the type's name. As with
+// match, type is only special at the start of a statement followed by a
+// name, so the function of that name still works.
+//
+// A type overloads operators by defining methods named __add, __sub, __mul,
+// __div, __mod, __neg, __eq and __lt. A binary operator looks for its method
+// on the left operand, then the right, and passes both operands in order.
+// >, <= and >= are derived from __lt, and != is the negation of __eq.
+// __index and __setindex receive the keys that are not fields or methods,
+// so p:0 can be made to mean p.x. Operators on numbers never look for
+// methods.
+//
+// # Matching
+//
+// A match statement runs the first case whose pattern fits its subject:
+//
+//	match shape
+//	case {"circle", r} -> print 3.14 * r * r
+//	case {"rect", w, h} if w == h -> print "square"
+//	case {first, ...rest}
+//		print first, rest
+//	case {"name": n} -> print n
+//	case 0 -> print "zero"
+//	case _ -> print "unknown"
+//	end
+//
+// Patterns are number, string and boolean literals; names, which match
+// anything and are assigned what they matched; _, which matches anything;
+// list patterns, which match lists of exactly their length unless they end
+// in ...name or ...; and map patterns, which match maps that have at least
+// their keys. A case may add an if guard. When no case matches nothing
+// runs, and a match whose cases are all literals gets a compiler warning, as
+// it probably lacks a default case. match is only special at the start of a
+// statement followed by cases, so the regex function of that name still
+// works.
+package aurora
@@ -0,0 +1,212 @@
+package aurora
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ErrStackOverflow is wrapped by the RuntimeError raised when a call would
+// exceed the VM's MaxCallDepth option.
+var ErrStackOverflow = errors.New("stack overflow")
+
+// backtraceHead and backtraceTail bound how many innermost and outermost
+// frames a formatted backtrace shows.
+const (
+	backtraceHead = 10
+	backtraceTail = 3
+)
+
+type TraceFrame struct {
+	Function string
+	Line     int
+}
+
+// Kinds of runtime error, as reported by RuntimeError.Kind and the kind
+// field of a caught error.
+const (
+	KindError       = "error"       // raised by throw or a built-in function
+	KindType        = "type"        // a value of the wrong type
+	KindIndex       = "index"       // an index out of range
+	KindName        = "name"        // an undefined variable
+	KindCall        = "call"        // a wrong number of arguments
+	KindStack       = "stack"       // too many nested calls
+	KindLimit       = "limit"       // an instruction or memory limit
+	KindInterrupted = "interrupted" // the run's context was cancelled
+)
+
+// RuntimeError is an error raised by a running script, such as applying an
+// operator to the wrong types. Scripts can catch them with try, except for
+// those of kind KindLimit and KindInterrupted.
+type RuntimeError struct {
+	Message string
+	Kind    string
+	Line    int
+	Trace   []TraceFrame // innermost frame first
+	Err     error
+	Value   Value // what a throw statement threw; nil for errors the VM raises
+}
+
+func (e *RuntimeError) Error() string {
+	msg := fmt.Sprintf("runtime error at line %d: %s", e.Line, e.Message)
+	if len(e.Trace) <= 1 {
+		return msg
+	}
+	var sb strings.Builder
+	sb.WriteString(msg)
+	head, tail := e.Trace, []TraceFrame(nil)
+	if len(e.Trace) > backtraceHead+backtraceTail {
+		head, tail = e.Trace[:backtraceHead], e.Trace[len(e.Trace)-backtraceTail:]
+	}
+	for _, frame := range head {
+		fmt.Fprintf(&sb, "\n  in %s at line %d", frame.Function, frame.Line)
+	}
+	if tail != nil {
+		fmt.Fprintf(&sb, "\n  ... %d more frames ...", len(e.Trace)-len(head)-len(tail))
+		for _, frame := range tail {
+			fmt.Fprintf(&sb, "\n  in %s at line %d", frame.Function, frame.Line)
+		}
+	}
+	return sb.String()
+}
+
+func (e *RuntimeError) Unwrap() error {
+	return e.Err
+}
+
+func frameLine(frame *callFrame) int {
+	if frame.pc == 0 {
+		return 0
+	}
+	return frame.function.body.lines[frame.pc-1]
+}
+
+// currentLine is the source line of the instruction being executed.
+func (vm *AuroraVM) currentLine() int {
+	if len(vm.callStack) == 0 {
+		return 0
+	}
+	return frameLine(&vm.callStack[len(vm.callStack)-1])
+}
+
+func (vm *AuroraVM) backtrace() []TraceFrame {
+	trace := make([]TraceFrame, len(vm.callStack))
+	for i := range vm.callStack {
+		frame := &vm.callStack[len(vm.callStack)-1-i]
+		trace[i] = TraceFrame{frame.function.name, frameLine(frame)}
+	}
+	return trace
+}
+
+// runtimeError aborts the current instruction with an error of kind
+// KindError. The VM unwinds to the nearest try block, or if there is none,
+// Run recovers the panic and returns the error to its caller.
+func (vm *AuroraVM) runtimeError(format string, args ...any) {
+	vm.fail(KindError, nil, format, args...)
+}
+
+// raise is runtimeError for failures that hosts can test for with errors.Is.
+func (vm *AuroraVM) raise(err error, format string, args ...any) {
+	kind := KindError
+	switch {
+	case errors.Is(err, ErrStackOverflow):
+		kind = KindStack
+	case errors.Is(err, ErrInstructionLimit), errors.Is(err, ErrMemoryLimit):
+		kind = KindLimit
+	case errors.Is(err, context.Canceled), errors.Is(err, context.DeadlineExceeded):
+		kind = KindInterrupted
+	}
+	vm.fail(kind, err, format, args...)
+}
+
+// fail is the general form of runtimeError and raise.
+func (vm *AuroraVM) fail(kind string, err error, format string, args ...any) {
+	panic(&RuntimeError{
+		Message: fmt.Sprintf(format, args...),
+		Kind:    kind,
+		Line:    vm.currentLine(),
+		Trace:   vm.backtrace(),
+		Err:     err,
+	})
+}
+
+// ErrorObject is the value a catch block receives. Scripts read its fields
+// by indexing it with "message", "kind", "line", "trace" and "value".
+type ErrorObject struct {
+	Message string
+	Kind    string
+	Line    int
+	Trace   []TraceFrame
+	Value   Value // what was thrown, or nil for errors the VM raised
+}
+
+func errorObject(err *RuntimeError) *ErrorObject {
+	return &ErrorObject{err.Message, err.Kind, err.Line, err.Trace, err.Value}
+}
+
+// throw raises v as a runtime error. Throwing a caught error rethrows it
+// with its original line and trace; any other value becomes the message of a
+// new error of kind KindError.
+func (vm *AuroraVM) throw(v Value) {
+	if v.kind == ErrorKind {
+		e := v.AsError()
+		panic(&RuntimeError{Message: e.Message, Kind: e.Kind, Line: e.Line, Trace: e.Trace, Value: e.Value})
+	}
+	panic(&RuntimeError{
+		Message: v.String(),
+		Kind:    KindError,
+		Line:    vm.currentLine(),
+		Trace:   vm.backtrace(),
+		Value:   v,
+	})
+}
+
+// catchable reports whether try blocks may handle err. Running out of budget
+// or being cancelled must stop the script however it is written.
+func catchable(err *RuntimeError) bool {
+	return err.Kind != KindLimit && err.Kind != KindInterrupted
+}
+
+// catch unwinds to the innermost handler in the frames from depth up that
+// covers the instruction each frame is executing. It stores the error in the
+// handler's register and reports whether it found one.
+func (vm *AuroraVM) catch(err *RuntimeError, depth int) bool {
+	if !catchable(err) {
+		return false
+	}
+	for i := len(vm.callStack) - 1; i >= depth; i-- {
+		frame := &vm.callStack[i]
+		for _, h := range frame.function.body.handlers {
+			if h.start < frame.pc && frame.pc <= h.end {
+				vm.callStack = vm.callStack[:i+1]
+				vm.stack = vm.stack[:frame.base+frame.function.body.locals+frame.function.body.registers]
+				frame.pc = h.target
+				frame.registers[h.register] = ErrorValue(errorObject(err))
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// errorField is the result of indexing a caught error with key.
+func errorField(e *ErrorObject, key Value) Value {
+	switch key.String() {
+	case "message":
+		return StringValue(e.Message)
+	case "kind":
+		return StringValue(e.Kind)
+	case "line":
+		return NumberValue(float64(e.Line))
+	case "trace":
+		trace := make([]string, len(e.Trace))
+		for i, frame := range e.Trace {
+			trace[i] = fmt.Sprintf("%s at line %d", frame.Function, frame.Line)
+		}
+		return stringList(trace)
+	case "value":
+		return e.Value
+	}
+	return Nil
+}
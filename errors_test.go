@@ -0,0 +1,170 @@
+package aurora
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestCatchRuntimeErrors(t *testing.T) {
+	runScripts(t, Options{}, []scriptCase{
+		{"type error", `try
+  x = 1 + "a"
+catch e
+  print e.kind, e.line, e.message
+end`, "type 2 unsupported operand types for +: 'number' and 'string'\n"},
+		{"index error", `xs = {1}
+try
+  x = xs:5
+catch e
+  print e.kind, e.line, e.message
+end`, "index 3 list index 5 out of range for length 1\n"},
+		{"name error", `try
+  x = missing
+catch e
+  print e.kind, e.message
+end`, "name undefined variable 'missing'\n"},
+		{"native error", `try
+  x = pop({})
+catch e
+  print e.kind, e.message
+end`, "error pop: pop from empty list\n"},
+		{"thrown value", `try
+  throw {1, 2}
+catch e
+  print e.kind, e.message, e.value
+end`, "error {1, 2} {1, 2}\n"},
+		{"error with a kind", `try
+  throw error("too big", "range")
+catch e
+  print e.kind, e.message, e.line, type(e.value)
+end`, "range too big 2 nil\n"},
+		{"execution continues after the try", `try
+  x = 1 + "a"
+catch e
+  x = 2
+end
+print x`, "2\n"},
+	})
+}
+
+func TestCatchUnwindsFrames(t *testing.T) {
+	runScripts(t, Options{}, []scriptCase{
+		{"across calls", `fn inner x
+  return x + "a"
+end
+fn outer x
+  y = inner(x)
+  return y
+end
+try
+  z = outer(1)
+catch e
+  print e.line, e.trace
+end`, `2 {"inner at line 2", "outer at line 5", "[script] at line 9"}` + "\n"},
+		{"inside a function", `fn safe x
+  try
+    return x:0
+  catch e
+    return e.kind
+  end
+end
+print safe({7}), safe({}), safe(1)`, "7 index type\n"},
+		{"through a native callback", `fn cmp a, b
+  throw error("bad order", "custom")
+end
+try
+  y = sort({2, 1}, cmp)
+catch e
+  print e.kind, e.message, e.line
+end`, "custom bad order 2\n"},
+		{"caught inside a native callback", `fn up groups
+  try
+    return groups:5
+  catch e
+    return "?"
+  end
+end
+print replace_re("\w", "ab", up)`, "??\n"},
+		{"rethrow keeps the line", `try
+  try
+    x = 1 + "a"
+  catch e
+    throw e
+  end
+catch e
+  print e.line, e.kind
+end`, "3 type\n"},
+		{"loop keeps running", `n = 0
+for x, {1, "a", 2}
+  try
+    n = n + x
+  catch e
+    n = n + 10
+  end
+end
+print n`, "13\n"},
+	})
+}
+
+func TestLimitErrorsAreNotCaught(t *testing.T) {
+	const loop = `caught = false
+try
+  while true
+  end
+catch e
+  caught = true
+end
+`
+	vm, err := run(t, Options{MaxInstructions: 10000}, loop)
+	if !errors.Is(err, ErrInstructionLimit) {
+		t.Errorf("got %v, want ErrInstructionLimit", err)
+	}
+	var caught bool
+	if vm.Get("caught", &caught); caught {
+		t.Error("try caught the instruction limit")
+	}
+	vm, err = run(t, Options{MaxMemory: 1 << 16}, `caught = false
+try
+  s = repeat("x", 100000)
+catch e
+  caught = true
+end
+`)
+	if !errors.Is(err, ErrMemoryLimit) {
+		t.Errorf("got %v, want ErrMemoryLimit", err)
+	}
+	if vm.Get("caught", &caught); caught {
+		t.Error("try caught the memory limit")
+	}
+}
+
+func TestInterruptsAreNotCaught(t *testing.T) {
+	program, err := Compile(`caught = false
+fn spin
+  while true
+  end
+end
+try
+  x = spin()
+catch e
+  caught = true
+end
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	vm := NewVM(Options{})
+	err = vm.RunContext(ctx, program)
+	var runtimeErr *RuntimeError
+	if !errors.As(err, &runtimeErr) || runtimeErr.Kind != KindInterrupted {
+		t.Errorf("got %v, want an interrupted error", err)
+	}
+	var caught bool
+	if vm.Get("caught", &caught); caught {
+		t.Error("try caught the interrupt")
+	}
+}
@@ -0,0 +1,98 @@
+package aurora_test
+
+import (
+	"fmt"
+	"log"
+	"strings"
+
+	aurora "aurorago"
+)
+
+func ExampleCompile() {
+	program, err := aurora.Compile(`match 1
+case 0 -> print "zero"
+end
+`)
+	if err != nil {
+		log.Fatal(err)
+	}
+	for _, warning := range program.Warnings() {
+		fmt.Println(warning)
+	}
+	// Output: line 1: match over literals has no default case
+}
+
+func ExampleAuroraVM_Run() {
+	program, err := aurora.Compile(`fn greet name -> "hello, " + name
+print greet("world")
+`)
+	if err != nil {
+		log.Fatal(err)
+	}
+	vm := aurora.NewVM(aurora.Options{})
+	if err := vm.Run(program); err != nil {
+		log.Fatal(err)
+	}
+	// Output: hello, world
+}
+
+func ExampleAuroraVM_Call() {
+	program, err := aurora.Compile("fn area w, h -> w * h\n")
+	if err != nil {
+		log.Fatal(err)
+	}
+	vm := aurora.NewVM(aurora.Options{})
+	if err := vm.Run(program); err != nil {
+		log.Fatal(err)
+	}
+	area, err := vm.Call("area", 3, 4)
+	if err != nil {
+		log.Fatal(err)
+	}
+	fmt.Println(area.AsNumber())
+	// Output: 12
+}
+
+func ExampleAuroraVM_Set() {
+	vm := aurora.NewVM(aurora.Options{})
+	if err := vm.Set("shout", strings.ToUpper); err != nil {
+		log.Fatal(err)
+	}
+	if err := vm.Set("names", []string{"ann", "bob"}); err != nil {
+		log.Fatal(err)
+	}
+	program, err := aurora.Compile(`for name, names
+  print shout(name)
+end
+`)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err := vm.Run(program); err != nil {
+		log.Fatal(err)
+	}
+	// Output:
+	// ANN
+	// BOB
+}
+
+func ExampleAuroraVM_Get() {
+	program, err := aurora.Compile(`squares = {}
+for n, {1, 2, 3, 4}
+  squares:len(squares) = n * n
+end
+`)
+	if err != nil {
+		log.Fatal(err)
+	}
+	vm := aurora.NewVM(aurora.Options{})
+	if err := vm.Run(program); err != nil {
+		log.Fatal(err)
+	}
+	var squares []int
+	if err := vm.Get("squares", &squares); err != nil {
+		log.Fatal(err)
+	}
+	fmt.Println(squares)
+	// Output: [1 4 9 16]
+}
@@ -1,102 +1,120 @@
-package main
+package aurora
 
-import "fmt"
+import (
+	"fmt"
+	"strings"
+)
 
-type TokenType int
+type tokenType int
 
-//go:generate stringer -type=TokenType
+//go:generate stringer -type=tokenType
 const (
-	IdTok TokenType = iota
-	NumberTok
-	StringTok
+	idTok tokenType = iota
+	numberTok
+	stringTok
 
-	NewlineTok
+	newlineTok
 
-	LparenTok
-	RparenTok
-	LbraceTok
-	RbraceTok
-	ArrowTok
-	CommaTok
-	ColonTok
+	lparenTok
+	rparenTok
+	lbraceTok
+	rbraceTok
+	arrowTok
+	commaTok
+	colonTok
+	dotDotTok
+	ellipsisTok
+	dotTok
 
-	PlusTok
-	MinusTok
-	StarTok
-	SlashTok
-	PercentTok
-	EqualTok
-	NotequalTok
-	LessTok
-	LessEqualTok
-	GreaterTok
-	GreaterEqualTok
-	AssignTok
-	PlusAssignTok
-	MinusAssignTok
-	StarAssignTok
-	SlashAssignTok
-	PercentAssignTok
+	plusTok
+	minusTok
+	starTok
+	slashTok
+	percentTok
+	equalTok
+	notequalTok
+	lessTok
+	lessEqualTok
+	greaterTok
+	greaterEqualTok
+	assignTok
+	plusAssignTok
+	minusAssignTok
+	starAssignTok
+	slashAssignTok
+	percentAssignTok
 
-	IfTok
-	ElseTok
-	WhileTok
-	ForTok
-	FnTok
-	SubTok
-	ReturnTok
-	BreakTok
-	ContinueTok
-	TrueTok
-	FalseTok
-	AndTok
-	OrTok
-	NotTok
-	EndTok
+	ifTok
+	elseTok
+	elifTok
+	whileTok
+	forTok
+	fnTok
+	subTok
+	returnTok
+	breakTok
+	continueTok
+	trueTok
+	falseTok
+	andTok
+	orTok
+	notTok
+	endTok
+	tryTok
+	catchTok
+	finallyTok
+	throwTok
+	caseTok
 
-	EofTok
+	eofTok
 )
 
-var keywords = map[string]TokenType{
-	"if":       IfTok,
-	"else":     ElseTok,
-	"while":    WhileTok,
-	"for":      ForTok,
-	"fn":       FnTok,
-	"sub":      SubTok,
-	"return":   ReturnTok,
-	"break":    BreakTok,
-	"continue": ContinueTok,
-	"true":     TrueTok,
-	"false":    FalseTok,
-	"and":      AndTok,
-	"or":       OrTok,
-	"not":      NotTok,
-	"end":      EndTok,
+var keywords = map[string]tokenType{
+	"if":       ifTok,
+	"else":     elseTok,
+	"elif":     elifTok,
+	"while":    whileTok,
+	"for":      forTok,
+	"fn":       fnTok,
+	"sub":      subTok,
+	"return":   returnTok,
+	"break":    breakTok,
+	"continue": continueTok,
+	"true":     trueTok,
+	"false":    falseTok,
+	"and":      andTok,
+	"or":       orTok,
+	"not":      notTok,
+	"end":      endTok,
+	"try":      tryTok,
+	"catch":    catchTok,
+	"finally":  finallyTok,
+	"throw":    throwTok,
+	"case":     caseTok,
 }
 
-type Token struct {
-	Type  TokenType
+type token struct {
+	Type  tokenType
 	Value string
 	Line  int
 }
 
-func (t Token) String() string {
+func (t token) String() string {
 	return fmt.Sprintf("Token(%s, '%s', %d)", t.Type.String(), t.Value, t.Line)
 }
 
-type Lexer struct {
+type lexer struct {
 	input string
 	start int
 	pos   int
 	line  int
 }
 
-func NewLexer(input string) *Lexer {
-	return &Lexer{input, 0, 0, 1}
+func newLexer(input string) *lexer {
+	return &lexer{input, 0, 0, 1}
 }
 
-func (l *Lexer) scanString() string {
+func (l *lexer) scanString() string {
 	l.pos++
 	start := l.pos
 	for l.pos < len(l.input) {
@@ -110,7 +128,7 @@ func (l *Lexer) scanString() string {
 	panic(fmt.Sprintf("Unterminated string at line %d", l.line))
 }
 
-func (l *Lexer) scanNumber() string {
+func (l *lexer) scanNumber() string {
 	start := l.pos
 	for l.pos < len(l.input) {
 		ch := l.input[l.pos]
@@ -119,7 +137,9 @@ func (l *Lexer) scanNumber() string {
 		}
 		l.pos++
 	}
-	if l.pos < len(l.input) && l.input[l.pos] == '.' {
+	// a dot not followed by a digit belongs to what comes next, as in
+	// xs:1..3 or grid:0.x
+	if l.pos+1 < len(l.input) && l.input[l.pos] == '.' && isDigit(l.input[l.pos+1]) {
 		l.pos++
 		for l.pos < len(l.input) {
 			ch := l.input[l.pos]
@@ -132,7 +152,7 @@ func (l *Lexer) scanNumber() string {
 	return l.input[start:l.pos]
 }
 
-func (l *Lexer) scanId() string {
+func (l *lexer) scanId() string {
 	start := l.pos
 	for l.pos < len(l.input) {
 		ch := l.input[l.pos]
@@ -152,7 +172,7 @@ func isDigit(ch byte) bool {
 	return ch >= '0' && ch <= '9'
 }
 
-func (l *Lexer) Next() Token {
+func (l *lexer) Next() token {
 	hadError := false
 	for l.pos < len(l.input) {
 		ch := l.input[l.pos]
@@ -160,113 +180,130 @@ func (l *Lexer) Next() Token {
 			panic(fmt.Sprintf("Unexpected character %c at line %d", ch, l.line))
 		}
 		switch ch {
-		case ' ', '\t':
+		case ' ', '\t', '\r':
 			l.pos++
 		case '\n':
-			l.pos++
-			l.line++
-			return Token{NewlineTok, "\n", l.line - 1}
+			// blank lines collapse into a single newline token
+			line := l.line
+			for l.pos < len(l.input) && strings.IndexByte(" \t\r\n", l.input[l.pos]) >= 0 {
+				if l.input[l.pos] == '\n' {
+					l.line++
+				}
+				l.pos++
+			}
+			return token{newlineTok, "\n", line}
 		case '(':
 			l.pos++
-			return Token{LparenTok, "(", l.line}
+			return token{lparenTok, "(", l.line}
 		case ')':
 			l.pos++
-			return Token{RparenTok, ")", l.line}
+			return token{rparenTok, ")", l.line}
 		case '{':
 			l.pos++
-			return Token{LbraceTok, "{", l.line}
+			return token{lbraceTok, "{", l.line}
 		case '}':
 			l.pos++
-			return Token{RbraceTok, "}", l.line}
+			return token{rbraceTok, "}", l.line}
 		case ',':
 			l.pos++
-			return Token{CommaTok, ",", l.line}
+			return token{commaTok, ",", l.line}
 		case ':':
 			l.pos++
-			return Token{ColonTok, ":", l.line}
+			return token{colonTok, ":", l.line}
+		case '.':
+			if strings.HasPrefix(l.input[l.pos:], "...") {
+				l.pos += 3
+				return token{ellipsisTok, "...", l.line}
+			}
+			if l.pos+1 < len(l.input) && l.input[l.pos+1] == '.' {
+				l.pos += 2
+				return token{dotDotTok, "..", l.line}
+			}
+			l.pos++
+			return token{dotTok, ".", l.line}
 		case '+':
 			l.pos++
 			if l.pos < len(l.input) && l.input[l.pos] == '=' {
 				l.pos++
-				return Token{PlusAssignTok, "+=", l.line}
+				return token{plusAssignTok, "+=", l.line}
 			}
-			return Token{PlusTok, "+", l.line}
+			return token{plusTok, "+", l.line}
 		case '-':
 			l.pos++
 			if l.pos < len(l.input) && l.input[l.pos] == '=' {
 				l.pos++
-				return Token{MinusAssignTok, "-=", l.line}
+				return token{minusAssignTok, "-=", l.line}
 			} else if l.pos < len(l.input) && l.input[l.pos] == '>' {
 				l.pos++
-				return Token{ArrowTok, "->", l.line}
+				return token{arrowTok, "->", l.line}
 			}
-			return Token{MinusTok, "-", l.line}
+			return token{minusTok, "-", l.line}
 		case '*':
 			l.pos++
 			if l.pos < len(l.input) && l.input[l.pos] == '=' {
 				l.pos++
-				return Token{StarAssignTok, "*=", l.line}
+				return token{starAssignTok, "*=", l.line}
 			}
-			return Token{StarTok, "*", l.line}
+			return token{starTok, "*", l.line}
 		case '/':
 			l.pos++
 			if l.pos < len(l.input) && l.input[l.pos] == '=' {
 				l.pos++
-				return Token{SlashAssignTok, "/=", l.line}
+				return token{slashAssignTok, "/=", l.line}
 			}
-			return Token{SlashTok, "/", l.line}
+			return token{slashTok, "/", l.line}
 		case '%':
 			l.pos++
 			if l.pos < len(l.input) && l.input[l.pos] == '=' {
 				l.pos++
-				return Token{PercentAssignTok, "%=", l.line}
+				return token{percentAssignTok, "%=", l.line}
 			}
-			return Token{PercentTok, "%", l.line}
+			return token{percentTok, "%", l.line}
 		case '=':
 			l.pos++
 			if l.pos < len(l.input) && l.input[l.pos] == '=' {
 				l.pos++
-				return Token{EqualTok, "==", l.line}
+				return token{equalTok, "==", l.line}
 			}
-			return Token{AssignTok, "=", l.line}
+			return token{assignTok, "=", l.line}
 		case '!':
 			l.pos++
 			if l.pos < len(l.input) && l.input[l.pos] == '=' {
 				l.pos++
-				return Token{NotequalTok, "!=", l.line}
+				return token{notequalTok, "!=", l.line}
 			}
 			hadError = true
 		case '<':
 			l.pos++
 			if l.pos < len(l.input) && l.input[l.pos] == '=' {
 				l.pos++
-				return Token{LessEqualTok, "<=", l.line}
+				return token{lessEqualTok, "<=", l.line}
 			}
-			return Token{LessTok, "<", l.line}
+			return token{lessTok, "<", l.line}
 		case '>':
 			l.pos++
 			if l.pos < len(l.input) && l.input[l.pos] == '=' {
 				l.pos++
-				return Token{GreaterEqualTok, ">=", l.line}
+				return token{greaterEqualTok, ">=", l.line}
 			}
-			return Token{GreaterTok, ">", l.line}
+			return token{greaterTok, ">", l.line}
 		case '&':
 			l.pos++
 			if l.pos < len(l.input) && l.input[l.pos] == '&' {
 				l.pos++
-				return Token{AndTok, "&&", l.line}
+				return token{andTok, "&&", l.line}
 			}
 			hadError = true
 		case '"':
-			return Token{
-				StringTok,
+			return token{
+				stringTok,
 				l.scanString(),
 				l.line,
 			}
 		default:
 			if isDigit(ch) {
-				return Token{
-					NumberTok,
+				return token{
+					numberTok,
 					l.scanNumber(),
 					l.line,
 				}
@@ -274,13 +311,13 @@ func (l *Lexer) Next() Token {
 			if isAlpha(ch) {
 				ident := l.scanId()
 				if tok, ok := keywords[ident]; ok {
-					return Token{tok, ident, l.line}
+					return token{tok, ident, l.line}
 				} else {
-					return Token{IdTok, ident, l.line}
+					return token{idTok, ident, l.line}
 				}
 			}
 			hadError = true
 		}
 	}
-	return Token{EofTok, "", l.line}
+	return token{eofTok, "", l.line}
 }
@@ -0,0 +1,94 @@
+package aurora
+
+import (
+	"errors"
+	"math"
+)
+
+// ErrInstructionLimit is wrapped by the RuntimeError raised when a run
+// executes more instructions than the VM's MaxInstructions option allows.
+var ErrInstructionLimit = errors.New("instruction limit exceeded")
+
+// ErrMemoryLimit is wrapped by the RuntimeError raised when a run allocates
+// more than the VM's MaxMemory option allows.
+var ErrMemoryLimit = errors.New("memory limit exceeded")
+
+// checkInterval is how many instructions run between checks of the context.
+const checkInterval = 1024
+
+// valueSize approximates the bytes a Value occupies in a list or map.
+const valueSize = 32
+
+// resetBudget starts the instruction and memory accounting for a new run.
+func (vm *AuroraVM) resetBudget() {
+	vm.steps = 0
+	vm.allocated = 0
+	vm.nextCheck = 0 // check the context before the first instruction
+}
+
+// checkBudget raises an error if the run is over its instruction limit or
+// its context is done, and schedules the next check. The dispatch loop only
+// calls it once steps reaches nextCheck, so an unlimited run without a
+// cancellable context pays for a single comparison per instruction.
+func (vm *AuroraVM) checkBudget() {
+	if vm.maxInstructions > 0 && vm.steps > vm.maxInstructions {
+		vm.raise(ErrInstructionLimit, "instruction limit of %d exceeded", vm.maxInstructions)
+	}
+	if err := vm.ctx.Err(); err != nil {
+		vm.raise(err, "execution interrupted: %v", err)
+	}
+	vm.nextCheck = math.MaxInt64
+	if vm.ctx.Done() != nil {
+		vm.nextCheck = vm.steps + checkInterval
+	}
+	if vm.maxInstructions > 0 && vm.maxInstructions < vm.nextCheck-1 {
+		vm.nextCheck = vm.maxInstructions + 1
+	}
+}
+
+// allocate charges size bytes against the memory limit. Call it before
+// building a string or list whose size depends on script data, so a runaway
+// script is stopped before the host runs out of memory.
+func (vm *AuroraVM) allocate(size int64) {
+	if vm.maxMemory <= 0 {
+		return
+	}
+	vm.allocated += size
+	if vm.allocated > vm.maxMemory || size < 0 {
+		vm.raise(ErrMemoryLimit, "memory limit of %d bytes exceeded", vm.maxMemory)
+	}
+}
+
+// sharesObject reports whether v is one of args, such as the list push
+// returns, which was already paid for.
+func sharesObject(v Value, args []Value) bool {
+	if v.obj == nil {
+		return false
+	}
+	for _, arg := range args {
+		if arg.kind == v.kind && arg.obj == v.obj {
+			return true
+		}
+	}
+	return false
+}
+
+// sizeOf approximates the bytes v's own object takes, not counting the
+// objects it refers to.
+func sizeOf(v Value) int64 {
+	switch v.kind {
+	case StringKind:
+		return int64(len(v.AsString()))
+	case ListKind:
+		return int64(len(v.AsList().items)) * valueSize
+	case RecordKind:
+		return int64(len(v.AsRecord().fields)) * valueSize
+	case MapKind:
+		size := int64(0)
+		for key := range v.AsMap().items {
+			size += int64(len(key)) + valueSize
+		}
+		return size
+	}
+	return 0
+}
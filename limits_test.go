@@ -0,0 +1,127 @@
+package aurora
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+)
+
+func TestNativesChargeBeforeBuilding(t *testing.T) {
+	for _, src := range []string{
+		`x = repeat("x", 100000000)`,
+		`s = repeat("x", 100000)
+x = join({s, s, s, s, s, s, s, s, s, s, s}, "")`,
+		`x = replace(repeat("a", 1000), "a", repeat("b", 10000))`,
+		`x = format("{}{}", repeat("x", 600000), repeat("y", 600000))`,
+		`x = json_stringify(1, 100000000)`,
+		// the same list over and over encodes to far more than it takes
+		`a = {1}
+i = 0
+while i < 40
+  a = {a, a}
+  i = i + 1
+end
+x = json_stringify(a)`,
+	} {
+		_, err := run(t, Options{MaxMemory: 1 << 20}, src+"\n")
+		if !errors.Is(err, ErrMemoryLimit) {
+			t.Errorf("%s: got %v, want ErrMemoryLimit", src, err)
+		}
+	}
+}
+
+func TestRepeatRejectsHugeResults(t *testing.T) {
+	_, err := run(t, Options{MaxMemory: 1 << 20}, "x = repeat(\"x\", 1125899906842624)\n")
+	var runtimeErr *RuntimeError
+	if !errors.As(err, &runtimeErr) {
+		t.Fatalf("got %v, want a RuntimeError", err)
+	}
+}
+
+func TestCallChargesNatives(t *testing.T) {
+	vm := NewVM(Options{MaxMemory: 10})
+	_, err := vm.Call("push", []int{1}, 2, 3, 4, 5)
+	var runtimeErr *RuntimeError
+	if !errors.As(err, &runtimeErr) || !errors.Is(err, ErrMemoryLimit) {
+		t.Fatalf("got %v, want a RuntimeError wrapping ErrMemoryLimit", err)
+	}
+}
+
+func TestHostCallsToNativesGetAFreshBudget(t *testing.T) {
+	vm, err := run(t, Options{MaxMemory: 1000}, "s = repeat(\"a\", 900)\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 3; i++ {
+		if _, err := vm.Call("repeat", "b", 900); err != nil {
+			t.Fatalf("call %d: %v", i+1, err)
+		}
+	}
+}
+
+// A list, map or record that contains itself cannot be printed, compared or
+// exported, and must fail with an error rather than overflow the Go stack.
+func TestCyclicValuesRaiseErrors(t *testing.T) {
+	const setup = `a = {1}
+x = push(a, a)
+b = {1}
+x = push(b, b)
+type Node next
+end
+n = Node(0)
+n.next = n
+m = Node(0)
+m.next = m
+`
+	for _, src := range []string{
+		"s = str(a)",
+		"print a",
+		"s = \"a\" + str({a})",
+		"s = json_stringify(a)",
+		"x = a == b",
+		"x = contains({b}, a)",
+		"s = str(n)",
+		"x = n == m",
+	} {
+		_, err := run(t, Options{Stdout: &bytes.Buffer{}}, setup+src+"\n")
+		var runtimeErr *RuntimeError
+		if !errors.As(err, &runtimeErr) || runtimeErr.Line != 11 {
+			t.Errorf("%s: got %v, want a runtime error at line 11", src, err)
+		}
+	}
+	vm, err := run(t, Options{}, setup+`try
+  s = str(a)
+catch e
+  message = e.message
+end
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var message string
+	if vm.Get("message", &message); message != "a list that contains itself cannot be converted to a string" {
+		t.Errorf("caught %q", message)
+	}
+	var export any
+	if err := vm.Get("a", &export); !errors.Is(err, ErrCyclicValue) {
+		t.Errorf("Get: got %v, want ErrCyclicValue", err)
+	}
+}
+
+func TestSharedItemsAreNotCycles(t *testing.T) {
+	vm, err := run(t, Options{}, `b = {1}
+a = {b, b, {b}}
+s = str(a)
+x = a == {b, {1}, {{1}}}
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var s string
+	var x bool
+	vm.Get("s", &s)
+	vm.Get("x", &x)
+	if s != "{{1}, {1}, {{1}}}" || !x {
+		t.Errorf("s = %q, x = %v", s, x)
+	}
+}
@@ -0,0 +1,88 @@
+package aurora
+
+import "fmt"
+
+// Variadic is the arity of a native function that accepts any number of
+// arguments.
+const Variadic = -1
+
+// NativeFunc implements a native function. It may call back into the VM, for
+// example with CallValue; a returned error becomes an Aurora runtime error.
+type NativeFunc func(vm *AuroraVM, args []Value) (Value, error)
+
+// NativeFunction is a Go function that scripts call like any other function.
+type NativeFunction struct {
+	Name  string
+	Arity int
+	Fn    NativeFunc
+}
+
+// Register defines the global name as a native function taking arity
+// arguments, or any number of them if arity is Variadic.
+func (vm *AuroraVM) Register(name string, arity int, fn NativeFunc) {
+	vm.globals[name] = NativeValue(&NativeFunction{name, arity, fn})
+}
+
+func (n *NativeFunction) checkArity(got int) error {
+	if n.Arity != Variadic && got != n.Arity {
+		return fmt.Errorf("%s expects %d arguments, got %d", n.Name, n.Arity, got)
+	}
+	return nil
+}
+
+// callNative runs a native function on behalf of opCall, turning its error
+// into a runtime error at the calling instruction. Natives that build large
+// results charge for them before building them; whatever the result takes
+// beyond that is charged afterwards.
+func (vm *AuroraVM) callNative(native *NativeFunction, args []Value) Value {
+	if err := native.checkArity(len(args)); err != nil {
+		vm.fail(KindCall, nil, "%v", err)
+	}
+	allocated := vm.allocated
+	result, err := native.Fn(vm, args)
+	if err != nil {
+		if runtimeErr, ok := err.(*RuntimeError); ok {
+			panic(runtimeErr)
+		}
+		vm.raise(err, "%s: %v", native.Name, err)
+	}
+	if vm.maxMemory > 0 && !sharesObject(result, args) {
+		if size := sizeOf(result) - (vm.allocated - allocated); size > 0 {
+			vm.allocate(size)
+		}
+	}
+	return result
+}
+
+// CallValue calls fn, which must be an Aurora or native function or a type,
+// with args.
+// Natives use it to call back into scripts.
+func (vm *AuroraVM) CallValue(fn Value, args ...Value) (Value, error) {
+	switch fn.kind {
+	case FunctionKind:
+		function := fn.AsFunction()
+		if len(args) != function.arity {
+			return Nil, fmt.Errorf("%s expects %d arguments, got %d", function.name, function.arity, len(args))
+		}
+		return vm.execute(function, args, typeFunction)
+	case NativeKind:
+		native := fn.AsNative()
+		if err := native.checkArity(len(args)); err != nil {
+			return Nil, err
+		}
+		if len(vm.callStack) == 0 {
+			vm.resetBudget()
+		}
+		// natives raise runtime errors, such as exceeding the memory limit,
+		// by panicking, which must not escape to a host calling in directly
+		var result Value
+		var err error
+		if runtimeErr := protect(func() { result, err = native.Fn(vm, args) }); runtimeErr != nil {
+			return Nil, runtimeErr
+		}
+		return result, err
+	case TypeKind:
+		return newRecord(fn.AsType(), args)
+	}
+	return Nil, fmt.Errorf("'%s' is not callable", fn.kind)
+}
@@ -0,0 +1,97 @@
+package aurora
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestRegisteredNatives(t *testing.T) {
+	program, err := Compile(`x = double(21)
+n = count(1, 2, 3)
+z = count()
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	vm := NewVM(Options{})
+	vm.Register("double", 1, func(vm *AuroraVM, args []Value) (Value, error) {
+		return NumberValue(args[0].AsNumber() * 2), nil
+	})
+	vm.Register("count", Variadic, func(vm *AuroraVM, args []Value) (Value, error) {
+		return NumberValue(float64(len(args))), nil
+	})
+	if err := vm.Run(program); err != nil {
+		t.Fatal(err)
+	}
+	var x, n, z int
+	vm.Get("x", &x)
+	vm.Get("n", &n)
+	vm.Get("z", &z)
+	if x != 42 || n != 3 || z != 0 {
+		t.Errorf("got %d, %d, %d; want 42, 3, 0", x, n, z)
+	}
+}
+
+func TestNativeArityIsChecked(t *testing.T) {
+	program, err := Compile("x = double(1, 2)\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	vm := NewVM(Options{})
+	vm.Register("double", 1, func(vm *AuroraVM, args []Value) (Value, error) {
+		return args[0], nil
+	})
+	err = vm.Run(program)
+	var runtimeErr *RuntimeError
+	if !errors.As(err, &runtimeErr) || runtimeErr.Kind != KindCall {
+		t.Errorf("got %v, want a call error", err)
+	}
+}
+
+var errNotFound = errors.New("not found")
+
+func TestNativeErrorsBecomeRuntimeErrors(t *testing.T) {
+	program, err := Compile(`try
+  x = lookup("k")
+catch e
+  message = e.message
+end
+y = lookup("k")
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	vm := NewVM(Options{})
+	vm.Register("lookup", 1, func(vm *AuroraVM, args []Value) (Value, error) {
+		return Nil, errNotFound
+	})
+	err = vm.Run(program)
+	if !errors.Is(err, errNotFound) {
+		t.Errorf("got %v, want it to wrap the native's error", err)
+	}
+	var message string
+	if vm.Get("message", &message); !strings.Contains(message, "lookup: not found") {
+		t.Errorf("caught %q", message)
+	}
+}
+
+func TestNativeCallsBackIntoScript(t *testing.T) {
+	program, err := Compile(`fn square n -> n * n
+x = apply(square, 7)
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	vm := NewVM(Options{})
+	vm.Register("apply", 2, func(vm *AuroraVM, args []Value) (Value, error) {
+		return vm.CallValue(args[0], args[1])
+	})
+	if err := vm.Run(program); err != nil {
+		t.Fatal(err)
+	}
+	var x int
+	if err := vm.Get("x", &x); err != nil || x != 49 {
+		t.Errorf("x = %d, %v; want 49", x, err)
+	}
+}
@@ -0,0 +1,283 @@
+package aurora
+
+import (
+	"math"
+	"strings"
+	"unicode/utf8"
+)
+
+var opSymbols = map[opcode]string{
+	opAdd:          "+",
+	opAddTo:        "+",
+	opSub:          "-",
+	opSubFrom:      "-",
+	opMul:          "*",
+	opDiv:          "/",
+	opMod:          "%",
+	opNeg:          "-",
+	opLess:         "<",
+	opLessEqual:    "<=",
+	opGreater:      ">",
+	opGreaterEqual: ">=",
+}
+
+// Records overload an operator by defining a method with one of these
+// names. The method is looked up on the left operand, then on the right, and
+// is called with both operands in order, so self is the left operand even
+// when only the right one defines it. a > b is b < a, a <= b is not b < a
+// and a >= b is not a < b.
+var overloads = map[opcode]string{
+	opAdd:          "__add",
+	opAddTo:        "__add",
+	opSub:          "__sub",
+	opSubFrom:      "__sub",
+	opMul:          "__mul",
+	opDiv:          "__div",
+	opMod:          "__mod",
+	opLess:         "__lt",
+	opLessEqual:    "__lt",
+	opGreater:      "__lt",
+	opGreaterEqual: "__lt",
+}
+
+// overload calls the method name of the first of operands that is a record
+// defining it, and reports whether there was one.
+func (vm *AuroraVM) overload(name string, operands ...Value) (Value, bool) {
+	for _, operand := range operands {
+		if operand.kind != RecordKind {
+			continue
+		}
+		if method, ok := operand.AsRecord().typ.methods[name]; ok {
+			return vm.callMethod(method, operands...), true
+		}
+	}
+	return Nil, false
+}
+
+// equal is valuesEqual, except that a record defining __eq decides for
+// itself what it equals.
+func (vm *AuroraVM) equal(a, b Value) bool {
+	if a.kind == RecordKind || b.kind == RecordKind {
+		if result, ok := vm.overload("__eq", a, b); ok {
+			return result.Truthy()
+		}
+	}
+	return valuesEqual(a, b)
+}
+
+func (vm *AuroraVM) operandError(op opcode, a, b Value) {
+	vm.fail(KindType, nil, "unsupported operand types for %s: '%s' and '%s'", opSymbols[op], a.kind, b.kind)
+}
+
+// arithmetic implements the binary arithmetic opcodes. step inlines the
+// number-and-number case of the three-operand forms before falling back here.
+func (vm *AuroraVM) arithmetic(op opcode, a, b Value) Value {
+	if a.kind == NumberKind && b.kind == NumberKind {
+		switch op {
+		case opAdd, opAddTo:
+			return NumberValue(a.number + b.number)
+		case opSub, opSubFrom:
+			return NumberValue(a.number - b.number)
+		case opMul:
+			return NumberValue(a.number * b.number)
+		case opDiv:
+			return NumberValue(a.number / b.number)
+		case opMod:
+			return NumberValue(math.Mod(a.number, b.number))
+		}
+	}
+	if a.kind == RecordKind || b.kind == RecordKind {
+		if result, ok := vm.overload(overloads[op], a, b); ok {
+			return result
+		}
+	}
+	switch op {
+	case opAdd, opAddTo:
+		switch {
+		case a.kind == StringKind && b.kind == StringKind:
+			vm.allocate(int64(len(a.AsString()) + len(b.AsString())))
+			return StringValue(a.AsString() + b.AsString())
+		case a.kind == ListKind && b.kind == ListKind:
+			x, y := a.AsList().items, b.AsList().items
+			vm.allocate(int64(len(x)+len(y)) * valueSize)
+			items := make([]Value, 0, len(x)+len(y))
+			return ListValue(append(append(items, x...), y...))
+		}
+	case opMul:
+		switch {
+		case a.kind == StringKind && b.kind == NumberKind:
+			return vm.repeat(a, b)
+		case a.kind == NumberKind && b.kind == StringKind:
+			return vm.repeat(b, a)
+		}
+	}
+	vm.operandError(op, a, b)
+	return Nil
+}
+
+// listIndex converts an index into a list of length n, which may be negative
+// to count back from the end, to a position in the list.
+func (vm *AuroraVM) listIndex(index Value, n int) int {
+	if index.kind != NumberKind || index.number != math.Trunc(index.number) {
+		vm.fail(KindType, nil, "list index must be an integer, not %s", index.repr())
+	}
+	i := index.number
+	if i < 0 {
+		i += float64(n)
+	}
+	if i < 0 || i >= float64(n) {
+		vm.fail(KindIndex, nil, "list index %s out of range for length %d", index, n)
+	}
+	return int(i)
+}
+
+// sliceBound converts a slice bound to a position in a list or string of
+// length n: nil means def, negative bounds count back from the end and
+// bounds past either end are clamped.
+func (vm *AuroraVM) sliceBound(bound Value, def, n int) int {
+	if bound.kind == NilKind {
+		return def
+	}
+	if bound.kind != NumberKind || bound.number != math.Trunc(bound.number) {
+		vm.fail(KindType, nil, "slice bound must be an integer, not %s", bound.repr())
+	}
+	i := bound.number
+	if i < 0 {
+		i += float64(n)
+	}
+	return int(math.Max(0, math.Min(i, float64(n))))
+}
+
+// slice returns a new list or string with the items or characters of v from
+// start up to, but not including, end.
+func (vm *AuroraVM) slice(v, start, end Value) Value {
+	switch v.kind {
+	case ListKind:
+		items := v.AsList().items
+		lo, hi := vm.sliceBound(start, 0, len(items)), vm.sliceBound(end, len(items), len(items))
+		if hi < lo {
+			hi = lo
+		}
+		vm.allocate(int64(hi-lo) * valueSize)
+		return ListValue(append([]Value(nil), items[lo:hi]...))
+	case StringKind:
+		s := v.AsString()
+		runes := []rune(s)
+		if len(runes) == len(s) {
+			runes = nil // ASCII: characters are bytes
+		}
+		n := len(s)
+		if runes != nil {
+			n = len(runes)
+		}
+		lo, hi := vm.sliceBound(start, 0, n), vm.sliceBound(end, n, n)
+		if hi < lo {
+			hi = lo
+		}
+		if runes == nil {
+			return StringValue(s[lo:hi])
+		}
+		vm.allocate(int64(hi - lo))
+		return StringValue(string(runes[lo:hi]))
+	}
+	vm.fail(KindType, nil, "cannot slice a %s", v.kind)
+	return Nil
+}
+
+func (vm *AuroraVM) repeat(s, n Value) Value {
+	count := n.number
+	if count < 0 || count != math.Trunc(count) {
+		vm.runtimeError("string repetition count must be a non-negative integer, got %s", n)
+	}
+	// check the count on its own too, as an empty string would let any
+	// count through, infinity included
+	size := float64(len(s.AsString())) * count
+	if count > math.MaxInt32 || size > math.MaxInt32 {
+		vm.runtimeError("string repetition result is too large")
+	}
+	vm.allocate(int64(size))
+	return StringValue(strings.Repeat(s.AsString(), int(count)))
+}
+
+// compareValues orders two numbers numerically or two strings
+// lexicographically by byte. It reports false for any other pair.
+func compareValues(a, b Value) (int, bool) {
+	switch {
+	case a.kind == NumberKind && b.kind == NumberKind:
+		switch {
+		case a.number < b.number:
+			return -1, true
+		case a.number > b.number:
+			return 1, true
+		}
+		return 0, true
+	case a.kind == StringKind && b.kind == StringKind:
+		return strings.Compare(a.AsString(), b.AsString()), true
+	}
+	return 0, false
+}
+
+// compare implements the ordering opcodes.
+func (vm *AuroraVM) compare(op opcode, a, b Value) bool {
+	if a.kind == NumberKind && b.kind == NumberKind {
+		switch op {
+		case opLess:
+			return a.number < b.number
+		case opLessEqual:
+			return a.number <= b.number
+		case opGreater:
+			return a.number > b.number
+		default:
+			return a.number >= b.number
+		}
+	}
+	if a.kind == RecordKind || b.kind == RecordKind {
+		x, y, negate := a, b, false
+		switch op {
+		case opLessEqual:
+			x, y, negate = b, a, true
+		case opGreater:
+			x, y = b, a
+		case opGreaterEqual:
+			negate = true
+		}
+		if result, ok := vm.overload(overloads[op], x, y); ok {
+			return result.Truthy() != negate
+		}
+	}
+	cmp, ok := compareValues(a, b)
+	if !ok {
+		vm.operandError(op, a, b)
+	}
+	switch op {
+	case opLess:
+		return cmp < 0
+	case opLessEqual:
+		return cmp <= 0
+	case opGreater:
+		return cmp > 0
+	default:
+		return cmp >= 0
+	}
+}
+
+// indexString returns the character at a character index of s.
+func (vm *AuroraVM) indexString(s string, index Value) Value {
+	if index.kind != NumberKind || index.number != math.Trunc(index.number) {
+		vm.fail(KindType, nil, "string index must be an integer, not %s", index.repr())
+	}
+	i := int(index.number)
+	if i < 0 {
+		i += utf8.RuneCountInString(s)
+	}
+	if i >= 0 {
+		for _, r := range s {
+			if i == 0 {
+				return StringValue(string(r))
+			}
+			i--
+		}
+	}
+	vm.fail(KindIndex, nil, "string index %d out of range for length %d", int(index.number), utf8.RuneCountInString(s))
+	return Nil
+}
@@ -0,0 +1,63 @@
+package aurora
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestRepeatRejectsHugeCounts(t *testing.T) {
+	for _, src := range []string{
+		`x = "" * (100000000000 * 1000000000)`,
+		`x = "" * (1 / 0)`,
+		`x = "ab" * 4294967296`,
+	} {
+		_, err := run(t, Options{}, src+"\n")
+		if err == nil || !strings.Contains(err.Error(), "too large") {
+			t.Errorf("%s: got %v, want a too large error", src, err)
+		}
+	}
+}
+
+func TestRepeat(t *testing.T) {
+	vm, err := run(t, Options{}, "x = \"ab\" * 3\ny = \"\" * 5\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var x, y string
+	if err := vm.Get("x", &x); err != nil || x != "ababab" {
+		t.Errorf("x = %q, %v", x, err)
+	}
+	if err := vm.Get("y", &y); err != nil || y != "" {
+		t.Errorf("y = %q, %v", y, err)
+	}
+}
+
+func TestIndexing(t *testing.T) {
+	runScripts(t, Options{}, []scriptCase{
+		{"index takes the whole expression", `xs = {10, 20, 30, 40}
+i = 1
+print xs:i + 1, xs:i * 2 - 1, (xs:i) + 1`, "30 20 21\n"},
+		{"negative indices", `xs = {10, 20, 30}
+print xs:-1, "abc":-3`, "30 a\n"},
+		{"chained indices", `grid = {{1, 2}, {3, 4}}
+i = 1
+print grid:1:0, grid:0:i, grid:(grid:0:0):i - 1`, "3 2 3\n"},
+		{"fields after an index", `type Point x, y
+end
+ps = {Point(1, 2), Point(3, 4)}
+i = 0
+print ps:0.x, ps:i.y, ps:(ps:0.x).x`, "1 2 3\n"},
+		{"calls in an index", `xs = {10, 20, 30}
+fn f n -> n
+print xs:f(1) + 1, xs:len(xs) - 1`, "30 30\n"},
+		{"slices", `xs = {10, 20, 30, 40}
+i = 1
+print xs:i..i + 2, xs:..-1, xs:2.., "héllo":1..3, xs:-9..9`, `{20, 30} {10, 20, 30} {30, 40} él {10, 20, 30, 40}` + "\n"},
+		{"assignment", `grid = {{1, 2}, {3, 4}}
+i = 1
+grid:1:i - 1 = 9
+xs = {}
+xs:0 = 1
+print grid, xs`, "{{1, 2}, {9, 4}} {1}\n"},
+	})
+}
@@ -1,427 +1,772 @@
-package main
+package aurora
 
 import (
 	"fmt"
 	"strconv"
 )
 
-type Parser struct {
-	lexer   *Lexer
-	current Token
+type parser struct {
+	lexer   *lexer
+	current token
+	// inIndex is set while parsing an index, where a colon or dot outside
+	// brackets starts the next index rather than indexing the last operand
+	inIndex bool
 }
 
-func NewParser(lexer *Lexer) *Parser {
-	parser := &Parser{lexer, Token{}}
+func newParser(lexer *lexer) *parser {
+	parser := &parser{lexer, token{}, false}
 	parser.current = parser.lexer.Next()
 	return parser
 }
 
-func (p *Parser) eat(typ TokenType) Token {
+func (p *parser) eat(typ tokenType) token {
 	if p.current.Type == typ {
 		toRet := p.current
 		p.current = p.lexer.Next()
 		return toRet
-	} else if typ == NewlineTok && p.current.Type == EofTok {
+	} else if typ == newlineTok && p.current.Type == eofTok {
 		return p.current
 	} else {
 		panic(fmt.Sprintf("Expected %s, got %s", typ, p.current.Type))
 	}
 }
 
-func (p *Parser) peek(typ TokenType) bool {
+func (p *parser) peek(typ tokenType) bool {
 	return p.current.Type == typ
 }
 
-func (p *Parser) peekNext() TokenType {
+func (p *parser) peekNext() tokenType {
 	return p.current.Type
 }
 
-func (p *Parser) ifStatement() Node {
-	line := p.eat(IfTok).Line
-	cond := p.expression()
-	if p.peek(NewlineTok) {
-		p.eat(NewlineTok)
-		stmts := make([]Node, 0)
-		elseStmts := make([]Node, 0)
-		for !p.peek(EndTok) && !p.peek(ElseTok) {
+func (p *parser) program() []node {
+	stmts := make([]node, 0)
+	for {
+		switch p.peekNext() {
+		case newlineTok:
+			p.eat(newlineTok)
+		case eofTok:
+			return stmts
+		default:
 			stmts = append(stmts, p.statement())
 		}
-		if p.peek(ElseTok) {
-			p.eat(ElseTok)
-			p.eat(NewlineTok)
-			for !p.peek(EndTok) {
-				elseStmts = append(elseStmts, p.statement())
+	}
+}
+
+func (p *parser) ifStatement() node {
+	line := p.eat(ifTok).Line
+	cond := p.expression()
+	if p.peek(newlineTok) {
+		p.eat(newlineTok)
+		stmts := p.block(endTok, elseTok, elifTok)
+		var elseIfs []elseIfClause
+		elseStmts := make([]node, 0)
+		for {
+			if p.peek(elifTok) {
+				elseIfs = append(elseIfs, p.elseIf(p.eat(elifTok).Line))
+				continue
+			}
+			if !p.peek(elseTok) {
+				break
 			}
+			elseLine := p.eat(elseTok).Line
+			if p.peek(ifTok) {
+				p.eat(ifTok)
+				elseIfs = append(elseIfs, p.elseIf(elseLine))
+				continue
+			}
+			p.eat(newlineTok)
+			elseStmts = p.block(endTok)
+			break
 		}
-		p.eat(EndTok)
-		p.eat(NewlineTok)
-		return If{cond, stmts, elseStmts, line}
+		p.eat(endTok)
+		p.eat(newlineTok)
+		return ifStmt{cond, stmts, elseIfs, elseStmts, line}
 	} else {
 		stmt := p.statement()
-		elseStmt := make([]Node, 0)
-		if p.peek(ElseTok) {
-			p.eat(ElseTok)
+		elseStmt := make([]node, 0)
+		if p.peek(elseTok) {
+			p.eat(elseTok)
 			elseStmt = append(elseStmt, p.statement())
 		}
-		return If{cond, []Node{stmt}, elseStmt, line}
+		return ifStmt{cond, []node{stmt}, nil, elseStmt, line}
 	}
 }
 
-func (p *Parser) whileStatement() Node {
-	line := p.eat(WhileTok).Line
+// elseIf parses the condition and block of an else if or elif branch, whose
+// keywords have been consumed.
+func (p *parser) elseIf(line int) elseIfClause {
 	cond := p.expression()
-	if p.peek(NewlineTok) {
-		p.eat(NewlineTok)
-		stmts := make([]Node, 0)
-		for !p.peek(EndTok) {
+	p.eat(newlineTok)
+	return elseIfClause{cond, p.block(endTok, elseTok, elifTok), line}
+}
+
+func (p *parser) whileStatement() node {
+	line := p.eat(whileTok).Line
+	cond := p.expression()
+	if p.peek(newlineTok) {
+		p.eat(newlineTok)
+		stmts := make([]node, 0)
+		for !p.peek(endTok) {
 			stmts = append(stmts, p.statement())
 		}
-		p.eat(EndTok)
-		p.eat(NewlineTok)
-		return While{cond, stmts, line}
+		p.eat(endTok)
+		p.eat(newlineTok)
+		return whileStmt{cond, stmts, line}
 	} else {
 		stmt := p.statement()
-		return While{cond, []Node{stmt}, line}
+		return whileStmt{cond, []node{stmt}, line}
 	}
 }
 
-func (p *Parser) forStatement() Node {
-	line := p.eat(ForTok).Line
-	name := p.eat(IdTok).Value
-	p.eat(CommaTok)
+func (p *parser) forStatement() node {
+	line := p.eat(forTok).Line
+	name := p.eat(idTok).Value
+	p.eat(commaTok)
+	names := []string{name}
 	iter := p.expression()
-	if p.peek(NewlineTok) {
-		p.eat(NewlineTok)
-		stmts := make([]Node, 0)
-		for !p.peek(EndTok) {
+	// in for a, b, pairs every name but the last is followed by a comma
+	for p.peek(commaTok) {
+		v, ok := iter.(variableExpr)
+		if !ok {
+			panic(fmt.Sprintf("Expected a name in 'for' at line %d", line))
+		}
+		p.eat(commaTok)
+		names = append(names, v.Name)
+		iter = p.expression()
+	}
+	if p.peek(newlineTok) {
+		p.eat(newlineTok)
+		stmts := make([]node, 0)
+		for !p.peek(endTok) {
 			stmts = append(stmts, p.statement())
 		}
-		p.eat(EndTok)
-		p.eat(NewlineTok)
-		return For{name, iter, stmts, line}
+		p.eat(endTok)
+		p.eat(newlineTok)
+		return forStmt{names, iter, stmts, line}
 	} else {
 		stmt := p.statement()
-		return For{name, iter, []Node{stmt}, line}
+		return forStmt{names, iter, []node{stmt}, line}
 	}
 }
 
-func (p *Parser) functionStatement() Node {
-	line := p.eat(FnTok).Line
-	name := p.eat(IdTok).Value
+func (p *parser) functionStatement() node {
+	line := p.eat(fnTok).Line
+	name := p.eat(idTok).Value
 	args := make([]string, 0)
-	if !p.peek(NewlineTok) && !p.peek(ArrowTok) {
+	if !p.peek(newlineTok) && !p.peek(arrowTok) {
 		for {
-			args = append(args, p.eat(IdTok).Value)
-			if p.peek(NewlineTok) || p.peek(ArrowTok) {
+			args = append(args, p.eat(idTok).Value)
+			if p.peek(newlineTok) || p.peek(arrowTok) {
 				break
 			}
-			p.eat(CommaTok)
+			p.eat(commaTok)
 		}
 	}
-	if p.peek(NewlineTok) {
-		p.eat(NewlineTok)
-		stmts := make([]Node, 0)
-		for !p.peek(EndTok) {
+	if p.peek(newlineTok) {
+		p.eat(newlineTok)
+		stmts := make([]node, 0)
+		for !p.peek(endTok) {
 			stmts = append(stmts, p.statement())
 		}
-		p.eat(EndTok)
-		p.eat(NewlineTok)
-		return Func{name, args, stmts, line}
+		p.eat(endTok)
+		p.eat(newlineTok)
+		return funcStmt{name, args, stmts, line}
 	} else {
-		p.eat(ArrowTok)
+		p.eat(arrowTok)
 		expr := p.expression()
-		p.eat(NewlineTok)
-		return Func{name, args, []Node{Return{expr, line}}, line}
+		p.eat(newlineTok)
+		return funcStmt{name, args, []node{returnStmt{expr, line}}, line}
 	}
 }
 
-func (p *Parser) subStatement() Node {
-	line := p.eat(SubTok).Line
-	name := p.eat(IdTok).Value
+func (p *parser) subStatement() node {
+	line := p.eat(subTok).Line
+	name := p.eat(idTok).Value
 	args := make([]string, 0)
-	if !p.peek(NewlineTok) && !p.peek(ArrowTok) {
+	if !p.peek(newlineTok) && !p.peek(arrowTok) {
 		for {
-			args = append(args, p.eat(IdTok).Value)
-			if p.peek(NewlineTok) || p.peek(ArrowTok) {
+			args = append(args, p.eat(idTok).Value)
+			if p.peek(newlineTok) || p.peek(arrowTok) {
 				break
 			}
-			p.eat(CommaTok)
+			p.eat(commaTok)
 		}
 	}
-	if p.peek(NewlineTok) {
-		p.eat(NewlineTok)
-		stmts := make([]Node, 0)
-		for !p.peek(EndTok) {
+	if p.peek(newlineTok) {
+		p.eat(newlineTok)
+		stmts := make([]node, 0)
+		for !p.peek(endTok) {
 			stmts = append(stmts, p.statement())
 		}
-		p.eat(EndTok)
-		p.eat(NewlineTok)
-		return Sub{name, args, stmts, line}
+		p.eat(endTok)
+		p.eat(newlineTok)
+		return subStmt{name, args, stmts, line}
 	} else {
-		p.eat(ArrowTok)
+		p.eat(arrowTok)
 		stmt := p.statement()
-		p.eat(NewlineTok)
-		return Sub{name, args, []Node{stmt}, line}
+		p.eat(newlineTok)
+		return subStmt{name, args, []node{stmt}, line}
 	}
 }
 
-func (p *Parser) returnStatement() Node {
-	line := p.eat(ReturnTok).Line
+func (p *parser) returnStatement() node {
+	line := p.eat(returnTok).Line
 	expr := p.expression()
-	p.eat(NewlineTok)
-	return Return{expr, line}
+	p.eat(newlineTok)
+	return returnStmt{expr, line}
 }
 
-func (p *Parser) breakStatement() Node {
-	line := p.eat(BreakTok).Line
-	p.eat(NewlineTok)
-	return Break{line}
+// block parses statements up to, but not including, one of the given
+// tokens.
+func (p *parser) block(ends ...tokenType) []node {
+	stmts := make([]node, 0)
+	for {
+		for _, end := range ends {
+			if p.peek(end) {
+				return stmts
+			}
+		}
+		stmts = append(stmts, p.statement())
+	}
 }
 
-func (p *Parser) continueStatement() Node {
-	line := p.eat(ContinueTok).Line
-	p.eat(NewlineTok)
-	return Continue{line}
+func (p *parser) tryStatement() node {
+	line := p.eat(tryTok).Line
+	p.eat(newlineTok)
+	try := tryStmt{Body: p.block(catchTok, finallyTok, endTok), Line: line}
+	if p.peek(catchTok) {
+		p.eat(catchTok)
+		if p.peek(idTok) {
+			try.CatchName = p.eat(idTok).Value
+		}
+		p.eat(newlineTok)
+		try.Catch = p.block(finallyTok, endTok)
+	}
+	if p.peek(finallyTok) {
+		p.eat(finallyTok)
+		p.eat(newlineTok)
+		try.Finally = p.block(endTok)
+	}
+	if try.Catch == nil && try.Finally == nil {
+		panic(fmt.Sprintf("'try' without 'catch' or 'finally' at line %d", line))
+	}
+	p.eat(endTok)
+	p.eat(newlineTok)
+	return try
 }
 
-func (p *Parser) statement() Node {
+func (p *parser) throwStatement() node {
+	line := p.eat(throwTok).Line
+	expr := p.expression()
+	p.eat(newlineTok)
+	return throwStmt{expr, line}
+}
+
+func (p *parser) breakStatement() node {
+	line := p.eat(breakTok).Line
+	p.eat(newlineTok)
+	return breakStmt{line}
+}
+
+func (p *parser) continueStatement() node {
+	line := p.eat(continueTok).Line
+	p.eat(newlineTok)
+	return continueStmt{line}
+}
+
+func (p *parser) statement() node {
 	switch p.peekNext() {
-	case IfTok:
+	case ifTok:
 		return p.ifStatement()
-	case WhileTok:
+	case whileTok:
 		return p.whileStatement()
-	case ForTok:
+	case forTok:
 		return p.forStatement()
-	case FnTok:
+	case fnTok:
 		return p.functionStatement()
-	case SubTok:
+	case subTok:
 		return p.subStatement()
-	case ReturnTok:
+	case returnTok:
 		return p.returnStatement()
-	case BreakTok:
+	case breakTok:
 		return p.breakStatement()
-	case ContinueTok:
+	case continueTok:
 		return p.continueStatement()
-	case IdTok:
-		name := p.eat(IdTok)
+	case tryTok:
+		return p.tryStatement()
+	case throwTok:
+		return p.throwStatement()
+	case idTok:
+		name := p.eat(idTok)
+		// type is not a keyword, so that the function of that name keeps
+		// working: type followed by a name declares a type
+		if name.Value == "type" && p.peek(idTok) {
+			return p.typeStatement(name.Line)
+		}
 		// TODO more assignment types
-		if p.peek(AssignTok) {
-			p.eat(AssignTok)
+		if p.peek(assignTok) {
+			p.eat(assignTok)
 			expr := p.expression()
-			p.eat(NewlineTok)
-			return Assignment{name.Value, expr, Assign, name.Line}
-		} else if p.peek(ColonTok) {
-			p.eat(ColonTok)
-			index := p.expression()
+			p.eat(newlineTok)
+			return assignStmt{name.Value, expr, assign, name.Line}
+		} else if p.peek(commaTok) {
+			return p.multiAssignment(name)
+		} else if p.peek(colonTok) || p.peek(dotTok) {
+			left := p.postfix(variableExpr{name.Value, name.Line})
+			if call, ok := left.(methodCallExpr); ok && !p.peek(assignTok) {
+				p.eat(newlineTok)
+				return call
+			}
+			target, ok := left.(indexExpr)
+			if !ok {
+				panic(fmt.Sprintf("Invalid assignment target at line %d", name.Line))
+			}
 			// TODO more assignment types
-			p.eat(AssignTok)
+			p.eat(assignTok)
 			expr := p.expression()
-			p.eat(NewlineTok)
-			return AssignIndex{name.Value, index, expr, Assign, name.Line}
+			p.eat(newlineTok)
+			return assignIndexStmt{target.Expr, target.Index, expr, assign, name.Line}
 		} else {
-			args := make([]Node, 0)
-			if !p.peek(NewlineTok) && !p.peek(EofTok) {
+			args := make([]node, 0)
+			if !p.peek(newlineTok) && !p.peek(eofTok) {
 				for {
 					args = append(args, p.expression())
-					if p.peek(NewlineTok) || p.peek(EofTok) {
+					if p.peek(newlineTok) || p.peek(eofTok) {
 						break
 					}
-					p.eat(CommaTok)
+					p.eat(commaTok)
 				}
 			}
-			p.eat(NewlineTok)
-			return FuncCall{Variable{name.Value, name.Line}, args, name.Line}
+			p.eat(newlineTok)
+			// match is not a keyword, so that the regex function keeps its
+			// name: match x followed by cases is a match statement
+			if name.Value == "match" && len(args) == 1 && p.peek(caseTok) {
+				return p.matchStatement(args[0], name.Line)
+			}
+			return callExpr{variableExpr{name.Value, name.Line}, args, name.Line}
 		}
-	case NewlineTok:
-		p.eat(NewlineTok)
+	case newlineTok:
+		p.eat(newlineTok)
 		return p.statement()
 	default:
 		panic("Unexpected token: " + p.peekNext().String())
 	}
 }
 
-func (p *Parser) primary() Node {
+// multiAssignment parses the rest of a, b = x, y after the first name.
+func (p *parser) multiAssignment(first token) node {
+	names := []string{first.Value}
+	for p.peek(commaTok) {
+		p.eat(commaTok)
+		names = append(names, p.eat(idTok).Value)
+	}
+	p.eat(assignTok)
+	right := []node{p.expression()}
+	for p.peek(commaTok) {
+		p.eat(commaTok)
+		right = append(right, p.expression())
+	}
+	p.eat(newlineTok)
+	if len(right) > 1 && len(right) != len(names) {
+		panic(fmt.Sprintf("Cannot assign %d values to %d names at line %d", len(right), len(names), first.Line))
+	}
+	return multiAssignStmt{names, right, first.Line}
+}
+
+// typeStatement parses a type declaration after the word type: the type's
+// name and fields, then its methods up to end.
+func (p *parser) typeStatement(line int) node {
+	decl := typeDecl{Name: p.eat(idTok).Value, Line: line}
+	for p.peek(idTok) {
+		field := p.eat(idTok).Value
+		for _, other := range decl.Fields {
+			if other == field {
+				panic(fmt.Sprintf("Duplicate field %s in type %s at line %d", field, decl.Name, line))
+			}
+		}
+		decl.Fields = append(decl.Fields, field)
+		if !p.peek(commaTok) {
+			break
+		}
+		p.eat(commaTok)
+	}
+	p.eat(newlineTok)
+	for !p.peek(endTok) {
+		if !p.peek(fnTok) {
+			panic(fmt.Sprintf("Expected a method in type %s, got %s at line %d", decl.Name, p.peekNext(), p.current.Line))
+		}
+		decl.Methods = append(decl.Methods, p.functionStatement().(funcStmt))
+	}
+	p.eat(endTok)
+	p.eat(newlineTok)
+	if len(decl.Fields)+len(decl.Methods) >= 0xff {
+		panic(fmt.Sprintf("Too many fields and methods in type %s at line %d", decl.Name, line))
+	}
+	return decl
+}
+
+// matchStatement parses the cases of a match statement after its subject.
+// A case is a pattern, an optional if guard and either -> and a statement or
+// a block running up to the next case or the end.
+func (p *parser) matchStatement(subject node, line int) node {
+	match := matchStmt{Subject: subject, Line: line}
+	for p.peek(caseTok) {
+		c := caseClause{Line: p.eat(caseTok).Line}
+		c.Pattern = p.pattern()
+		if p.peek(ifTok) {
+			p.eat(ifTok)
+			c.Guard = p.expression()
+		}
+		if p.peek(arrowTok) {
+			p.eat(arrowTok)
+			c.Body = []node{p.statement()}
+		} else {
+			p.eat(newlineTok)
+			c.Body = p.block(caseTok, endTok)
+		}
+		match.Cases = append(match.Cases, c)
+	}
+	p.eat(endTok)
+	p.eat(newlineTok)
+	return match
+}
+
+func (p *parser) pattern() pattern {
+	line := p.current.Line
 	switch p.peekNext() {
-	case NumberTok:
-		numToken := p.eat(NumberTok)
+	case numberTok, minusTok:
+		negate := p.peek(minusTok)
+		if negate {
+			p.eat(minusTok)
+		}
+		v, _ := strconv.ParseFloat(p.eat(numberTok).Value, 64)
+		if negate {
+			v = -v
+		}
+		return literalPattern{NumberValue(v)}
+	case stringTok:
+		return literalPattern{StringValue(p.eat(stringTok).Value)}
+	case trueTok:
+		p.eat(trueTok)
+		return literalPattern{BoolValue(true)}
+	case falseTok:
+		p.eat(falseTok)
+		return literalPattern{BoolValue(false)}
+	case idTok:
+		name := p.eat(idTok).Value
+		if name == "_" {
+			return wildcardPattern{}
+		}
+		return bindPattern{name}
+	case lbraceTok:
+		return p.collectionPattern()
+	}
+	panic(fmt.Sprintf("Invalid pattern at line %d", line))
+}
+
+// collectionPattern parses a list pattern such as {first, ...rest}, or a map
+// pattern such as {"name": n} when the first item is a string and a colon.
+func (p *parser) collectionPattern() pattern {
+	p.eat(lbraceTok)
+	var list listPattern
+	for !p.peek(rbraceTok) {
+		if p.peek(ellipsisTok) {
+			p.eat(ellipsisTok)
+			list.HasRest = true
+			if p.peek(idTok) {
+				list.Rest = p.eat(idTok).Value
+			}
+			break
+		}
+		item := p.pattern()
+		if lit, ok := item.(literalPattern); ok && len(list.Items) == 0 &&
+			lit.Value.kind == StringKind && p.peek(colonTok) {
+			return p.mapPattern(lit.Value.AsString())
+		}
+		list.Items = append(list.Items, item)
+		if !p.peek(rbraceTok) {
+			p.eat(commaTok)
+		}
+	}
+	p.eat(rbraceTok)
+	if len(list.Items) > 0xff {
+		panic(fmt.Sprintf("Too many items in list pattern at line %d", p.current.Line))
+	}
+	return list
+}
+
+// mapPattern parses the rest of a map pattern after its first key.
+func (p *parser) mapPattern(key string) pattern {
+	var m mapPattern
+	for {
+		p.eat(colonTok)
+		m.Keys = append(m.Keys, key)
+		m.Values = append(m.Values, p.pattern())
+		if p.peek(rbraceTok) {
+			break
+		}
+		p.eat(commaTok)
+		key = p.eat(stringTok).Value
+	}
+	p.eat(rbraceTok)
+	return m
+}
+
+func (p *parser) primary() node {
+	switch p.peekNext() {
+	case numberTok:
+		numToken := p.eat(numberTok)
 		v, _ := strconv.ParseFloat(numToken.Value, 64)
-		return Number{v, numToken.Line}
-	case StringTok:
-		strToken := p.eat(StringTok)
-		return String{strToken.Value, strToken.Line}
-	case IdTok:
-		idToken := p.eat(IdTok)
-		return Variable{idToken.Value, idToken.Line}
-	case TrueTok:
-		val := p.eat(TrueTok)
-		return Bool{true, val.Line}
-	case FalseTok:
-		val := p.eat(FalseTok)
-		return Bool{false, val.Line}
-	case LparenTok:
-		p.eat(LparenTok)
-		expr := p.expression()
-		p.eat(RparenTok)
+		return numberLit{v, numToken.Line}
+	case stringTok:
+		strToken := p.eat(stringTok)
+		return stringLit{strToken.Value, strToken.Line}
+	case idTok:
+		idToken := p.eat(idTok)
+		return variableExpr{idToken.Value, idToken.Line}
+	case trueTok:
+		val := p.eat(trueTok)
+		return boolLit{true, val.Line}
+	case falseTok:
+		val := p.eat(falseTok)
+		return boolLit{false, val.Line}
+	case lparenTok:
+		p.eat(lparenTok)
+		expr := p.bracketed()
+		p.eat(rparenTok)
 		return expr
-	case LbraceTok:
-		line := p.eat(LbraceTok).Line
-		exprs := make([]Node, 0)
-		if !p.peek(RbraceTok) {
+	case lbraceTok:
+		line := p.eat(lbraceTok).Line
+		exprs := make([]node, 0)
+		if !p.peek(rbraceTok) {
 			for {
-				exprs = append(exprs, p.expression())
-				if p.peek(RbraceTok) {
+				exprs = append(exprs, p.bracketed())
+				if p.peek(rbraceTok) {
 					break
 				}
-				p.eat(CommaTok)
+				p.eat(commaTok)
 			}
 		}
-		p.eat(RbraceTok)
-		return List{exprs, line}
+		p.eat(rbraceTok)
+		return listLit{exprs, line}
 	default:
 		panic("Unexpected token: " + p.peekNext().String())
 	}
 }
 
-func (p *Parser) call() Node {
-	expr := p.primary()
+func (p *parser) call() node {
+	return p.postfix(p.primary())
+}
+
+// postfix parses the calls and indexing that follow expr, as in f(x):0 or
+// grid:y:x.
+func (p *parser) postfix(expr node) node {
 	for {
 		switch p.peekNext() {
-		case LparenTok:
-			line := p.eat(LparenTok).Line
-			args := make([]Node, 0)
-			if !p.peek(RparenTok) {
-				for {
-					args = append(args, p.expression())
-					if p.peek(RparenTok) {
-						break
-					}
-					p.eat(CommaTok)
-				}
+		case lparenTok:
+			expr = p.arguments(expr)
+		case colonTok:
+			if p.inIndex {
+				return expr
+			}
+			line := p.eat(colonTok).Line
+			expr = p.indexOrSlice(expr, line)
+		case dotTok:
+			if p.inIndex {
+				return expr
+			}
+			// p.name is p:"name", and p.name(args) calls a method
+			line := p.eat(dotTok).Line
+			name := p.eat(idTok).Value
+			if p.peek(lparenTok) {
+				call := p.arguments(expr).(callExpr)
+				expr = methodCallExpr{expr, name, call.Args, line}
+			} else {
+				expr = indexExpr{expr, stringLit{name, line}, line}
 			}
-			p.eat(RparenTok)
-			expr = FuncCall{expr, args, line}
-		case ColonTok:
-			line := p.eat(ColonTok).Line
-			index := p.expression()
-			expr = Index{expr, index, line}
 		default:
 			return expr
 		}
 	}
 }
 
-func (p *Parser) unary() Node {
+// arguments parses the parenthesised arguments of a call to fn.
+func (p *parser) arguments(fn node) node {
+	line := p.eat(lparenTok).Line
+	args := make([]node, 0)
+	if !p.peek(rparenTok) {
+		for {
+			args = append(args, p.bracketed())
+			if p.peek(rparenTok) {
+				break
+			}
+			p.eat(commaTok)
+		}
+	}
+	p.eat(rparenTok)
+	return callExpr{fn, args, line}
+}
+
+// bracketed parses an expression inside parentheses or braces, where colons
+// index as usual even within an index.
+func (p *parser) bracketed() node {
+	inIndex := p.inIndex
+	p.inIndex = false
+	defer func() { p.inIndex = inIndex }()
+	return p.expression()
+}
+
+// index parses an index or slice bound. It is a whole expression, so xs:i + 1
+// is the item after xs:i, except that a colon or dot outside brackets starts
+// the next index, so grid:y:x is (grid:y):x and ps:0.x is (ps:0).x.
+func (p *parser) index() node {
+	inIndex := p.inIndex
+	p.inIndex = true
+	defer func() { p.inIndex = inIndex }()
+	return p.expression()
+}
+
+// startsExpression reports whether the current token can begin an
+// expression, to tell xs:1..n from an open-ended xs:1.. followed by
+// something else.
+func (p *parser) startsExpression() bool {
+	switch p.peekNext() {
+	case numberTok, stringTok, idTok, trueTok, falseTok, lparenTok, lbraceTok, minusTok, notTok:
+		return true
+	}
+	return false
+}
+
+// indexOrSlice parses what follows the colon in expr:index or
+// expr:start..end, where either bound of a slice may be left out.
+func (p *parser) indexOrSlice(expr node, line int) node {
+	var start, end node
+	if !p.peek(dotDotTok) {
+		start = p.index()
+		if !p.peek(dotDotTok) {
+			return indexExpr{expr, start, line}
+		}
+	}
+	p.eat(dotDotTok)
+	if p.startsExpression() {
+		end = p.index()
+	}
+	return sliceExpr{expr, start, end, line}
+}
+
+func (p *parser) unary() node {
 	switch p.peekNext() {
-	case MinusTok:
-		line := p.eat(MinusTok).Line
-		return Unary{p.unary(), Minus, line}
-	case NotTok:
-		line := p.eat(NotTok).Line
-		return Unary{p.unary(), Not, line}
+	case minusTok:
+		line := p.eat(minusTok).Line
+		return unaryExpr{p.unary(), minus, line}
+	case notTok:
+		line := p.eat(notTok).Line
+		return unaryExpr{p.unary(), not, line}
 	default:
 		return p.call()
 	}
 }
 
-func (p *Parser) factor() Node {
+func (p *parser) factor() node {
 	expr := p.unary()
 	for {
 		switch p.peekNext() {
-		case StarTok:
-			line := p.eat(StarTok).Line
-			expr = Binary{expr, p.unary(), Multiply, line}
-		case SlashTok:
-			line := p.eat(SlashTok).Line
-			expr = Binary{expr, p.unary(), Divide, line}
-		case PercentTok:
-			line := p.eat(PercentTok).Line
-			expr = Binary{expr, p.unary(), Modulo, line}
+		case starTok:
+			line := p.eat(starTok).Line
+			expr = binaryExpr{expr, p.unary(), multiply, line}
+		case slashTok:
+			line := p.eat(slashTok).Line
+			expr = binaryExpr{expr, p.unary(), divide, line}
+		case percentTok:
+			line := p.eat(percentTok).Line
+			expr = binaryExpr{expr, p.unary(), modulo, line}
 		default:
 			return expr
 		}
 	}
 }
 
-func (p *Parser) term() Node {
+func (p *parser) term() node {
 	expr := p.factor()
 	for {
 		switch p.peekNext() {
-		case PlusTok:
-			line := p.eat(PlusTok).Line
-			expr = Binary{expr, p.factor(), Plus, line}
-		case MinusTok:
-			line := p.eat(MinusTok).Line
-			expr = Binary{expr, p.factor(), Minus, line}
+		case plusTok:
+			line := p.eat(plusTok).Line
+			expr = binaryExpr{expr, p.factor(), plus, line}
+		case minusTok:
+			line := p.eat(minusTok).Line
+			expr = binaryExpr{expr, p.factor(), minus, line}
 		default:
 			return expr
 		}
 	}
 }
 
-func (p *Parser) comparison() Node {
+func (p *parser) comparison() node {
 	expr := p.term()
 	for {
 		switch p.peekNext() {
-		case GreaterTok:
-			line := p.eat(GreaterTok).Line
-			expr = Binary{expr, p.term(), Greater, line}
-		case GreaterEqualTok:
-			line := p.eat(GreaterEqualTok).Line
-			expr = Binary{expr, p.term(), GreaterEqual, line}
-		case LessTok:
-			line := p.eat(LessTok).Line
-			expr = Binary{expr, p.term(), Less, line}
-		case LessEqualTok:
-			line := p.eat(LessEqualTok).Line
-			expr = Binary{expr, p.term(), LessEqual, line}
+		case greaterTok:
+			line := p.eat(greaterTok).Line
+			expr = binaryExpr{expr, p.term(), greater, line}
+		case greaterEqualTok:
+			line := p.eat(greaterEqualTok).Line
+			expr = binaryExpr{expr, p.term(), greaterEqual, line}
+		case lessTok:
+			line := p.eat(lessTok).Line
+			expr = binaryExpr{expr, p.term(), less, line}
+		case lessEqualTok:
+			line := p.eat(lessEqualTok).Line
+			expr = binaryExpr{expr, p.term(), lessEqual, line}
 		default:
 			return expr
 		}
 	}
 }
 
-func (p *Parser) equality() Node {
+func (p *parser) equality() node {
 	expr := p.comparison()
 	for {
 		switch p.peekNext() {
-		case EqualTok:
-			line := p.eat(EqualTok).Line
-			expr = Binary{expr, p.comparison(), Equal, line}
-		case NotequalTok:
-			line := p.eat(NotequalTok).Line
-			expr = Binary{expr, p.comparison(), NotEqual, line}
+		case equalTok:
+			line := p.eat(equalTok).Line
+			expr = binaryExpr{expr, p.comparison(), equal, line}
+		case notequalTok:
+			line := p.eat(notequalTok).Line
+			expr = binaryExpr{expr, p.comparison(), notEqual, line}
 		default:
 			return expr
 		}
 	}
 }
 
-func (p *Parser) and() Node {
+func (p *parser) and() node {
 	expr := p.equality()
 	for {
 		switch p.peekNext() {
-		case AndTok:
-			line := p.eat(AndTok).Line
-			expr = Binary{expr, p.equality(), And, line}
+		case andTok:
+			line := p.eat(andTok).Line
+			expr = binaryExpr{expr, p.equality(), and, line}
 		default:
 			return expr
 		}
 	}
 }
 
-func (p *Parser) or() Node {
+func (p *parser) or() node {
 	expr := p.and()
 	for {
 		switch p.peekNext() {
-		case OrTok:
-			line := p.eat(OrTok).Line
-			expr = Binary{expr, p.and(), Or, line}
+		case orTok:
+			line := p.eat(orTok).Line
+			expr = binaryExpr{expr, p.and(), or, line}
 		default:
 			return expr
 		}
 	}
 }
 
-func (p *Parser) expression() Node {
+func (p *parser) expression() node {
 	return p.or()
 }
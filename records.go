@@ -0,0 +1,123 @@
+package aurora
+
+import "fmt"
+
+// RecordType is a type declared with type. Calling it makes a record with a
+// value for each of its fields, in order.
+type RecordType struct {
+	name    string
+	fields  []string
+	methods map[string]Value
+}
+
+// RecordObject is an instance of a RecordType. Like lists, records are shared
+// by reference, so changing a field through one reference is visible through
+// all of them.
+type RecordObject struct {
+	typ    *RecordType
+	fields []Value
+}
+
+func TypeValue(t *RecordType) Value {
+	return Value{kind: TypeKind, obj: t}
+}
+
+func RecordValue(r *RecordObject) Value {
+	return Value{kind: RecordKind, obj: r}
+}
+
+func (v Value) AsType() *RecordType {
+	return v.obj.(*RecordType)
+}
+
+func (v Value) AsRecord() *RecordObject {
+	return v.obj.(*RecordObject)
+}
+
+// Name returns the name the type was declared with.
+func (t *RecordType) Name() string {
+	return t.name
+}
+
+// field returns the position of the named field, or -1 if there is none.
+func (t *RecordType) field(name string) int {
+	for i, field := range t.fields {
+		if field == name {
+			return i
+		}
+	}
+	return -1
+}
+
+// newRecord makes an instance of t from one argument per field.
+func newRecord(t *RecordType, args []Value) (Value, error) {
+	if len(args) != len(t.fields) {
+		return Nil, fmt.Errorf("%s expects %d arguments, got %d", t.name, len(t.fields), len(args))
+	}
+	fields := make([]Value, len(args))
+	copy(fields, args)
+	return RecordValue(&RecordObject{t, fields}), nil
+}
+
+// recordField reads a field of r, or failing that one of its type's methods,
+// which is returned unbound and takes the record as its first argument. Any
+// other key goes to the type's __index method, if it has one.
+func (vm *AuroraVM) recordField(r *RecordObject, key Value) Value {
+	if key.kind == StringKind {
+		if i := r.typ.field(key.AsString()); i >= 0 {
+			return r.fields[i]
+		}
+		if method, ok := r.typ.methods[key.AsString()]; ok {
+			return method
+		}
+	}
+	if index, ok := r.typ.methods["__index"]; ok {
+		return vm.callMethod(index, RecordValue(r), key)
+	}
+	vm.fail(KindName, nil, "%s has no field '%s'", r.typ.name, key)
+	return Nil
+}
+
+// setRecordField assigns to an existing field of r; records cannot gain
+// fields their type does not declare. Any other key goes to the type's
+// __setindex method, if it has one.
+func (vm *AuroraVM) setRecordField(r *RecordObject, key, value Value) {
+	if key.kind == StringKind {
+		if i := r.typ.field(key.AsString()); i >= 0 {
+			r.fields[i] = value
+			return
+		}
+	}
+	if setIndex, ok := r.typ.methods["__setindex"]; ok {
+		vm.callMethod(setIndex, RecordValue(r), key, value)
+		return
+	}
+	vm.fail(KindName, nil, "%s has no field '%s'", r.typ.name, key)
+}
+
+// callMethod calls a method on behalf of an instruction, such as an
+// overloaded operator, re-raising its errors so that they unwind the script
+// that ran the instruction.
+func (vm *AuroraVM) callMethod(method Value, args ...Value) Value {
+	result, err := vm.CallValue(method, args...)
+	if err != nil {
+		if runtimeErr, ok := err.(*RuntimeError); ok {
+			panic(runtimeErr)
+		}
+		vm.fail(KindCall, err, "%v", err)
+	}
+	return result
+}
+
+// method looks up the method name for a call on receiver.
+func (vm *AuroraVM) method(receiver Value, name string) Value {
+	if receiver.kind != RecordKind {
+		vm.fail(KindType, nil, "cannot call method '%s' on a %s", name, receiver.kind)
+	}
+	t := receiver.AsRecord().typ
+	method, ok := t.methods[name]
+	if !ok {
+		vm.fail(KindName, nil, "%s has no method '%s'", t.name, name)
+	}
+	return method
+}
@@ -0,0 +1,99 @@
+package aurora
+
+import "testing"
+
+const pointType = `type Point x, y
+  fn norm -> sqrt(self.x * self.x + self.y * self.y)
+  fn move dx, dy
+    self.x = self.x + dx
+    self.y = self.y + dy
+  end
+end
+`
+
+func TestRecords(t *testing.T) {
+	runScripts(t, Options{}, []scriptCase{
+		{"fields", pointType + `p = Point(3, 4)
+print p.x, p:"y", type(p), type(Point), Point`, "3 4 Point type <type Point>\n"},
+		{"printing", pointType + `print Point(3, {1, "a"})`, `Point(x: 3, y: {1, "a"})` + "\n"},
+		{"methods", pointType + `p = Point(3, 4)
+print p.norm()
+x = p.move(1, 1)
+print p`, "5\nPoint(x: 4, y: 5)\n"},
+		{"unbound methods take self first", pointType + `f = Point(3, 4).norm
+print f(Point(6, 8))`, "10\n"},
+		{"shared by reference", pointType + `p = Point(1, 2)
+q = p
+q.x = 9
+p:"y" = 8
+print p, q`, "Point(x: 9, y: 8) Point(x: 9, y: 8)\n"},
+		{"equality by value", pointType + `type Pair x, y
+end
+print Point(1, 2) == Point(1, 2), Point(1, 2) == Point(1, 3), Point(1, 2) == Pair(1, 2), Point(1, 2) != Point(2, 1)`, "true false false true\n"},
+	})
+	runScriptErrors(t, Options{}, []scriptCase{
+		{"wrong arity", pointType + `p = Point(1)`, "Point expects 2 arguments, got 1"},
+		{"missing field", pointType + `x = Point(1, 2).z`, "Point has no field 'z'"},
+		{"no new fields", pointType + "p = Point(1, 2)\np.z = 1", "Point has no field 'z'"},
+		{"missing method", pointType + `x = Point(1, 2).nope()`, "Point has no method 'nope'"},
+	})
+}
+
+func TestCallType(t *testing.T) {
+	vm, err := run(t, Options{}, pointType)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p, err := vm.Call("Point", 3, 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.String() != "Point(x: 3, y: 4)" {
+		t.Errorf("Point(3, 4) = %v", p)
+	}
+	export, ok := p.Export().(map[string]any)
+	if !ok || export["x"] != 3.0 || export["y"] != 4.0 {
+		t.Errorf("exported %#v, want a map of the fields", p.Export())
+	}
+	if _, err := vm.Call("Point", 3); err == nil {
+		t.Error("calling Point with one argument succeeded")
+	}
+}
+
+const vecType = `type Vec x, y
+  fn __add o -> Vec(self.x + o.x, self.y + o.y)
+  fn __sub o -> Vec(self.x - o.x, self.y - o.y)
+  fn __mul k -> Vec(self.x * k, self.y * k)
+  fn __div o -> type(self) + "/" + type(o)
+  fn __neg -> Vec(-self.x, -self.y)
+  fn __eq o -> self.x == o.x
+  fn __lt o -> self.x < o.x
+  fn __index i -> self.x * 10 + i
+  fn __setindex i, v
+    self.x = v + i
+  end
+end
+a = Vec(1, 2)
+b = Vec(3, 4)
+`
+
+func TestOperatorOverloading(t *testing.T) {
+	runScripts(t, Options{}, []scriptCase{
+		{"arithmetic", vecType + `print a + b, b - a, a * 3, -a`, "Vec(x: 4, y: 6) Vec(x: 2, y: 2) Vec(x: 3, y: 6) Vec(x: -1, y: -2)\n"},
+		{"operands are passed in order", vecType + `print a / 2, 2 / a`, "Vec/number number/Vec\n"},
+		{"equality", vecType + `print a == Vec(1, 9), a != Vec(1, 9), a == b, a != b`, "true false false true\n"},
+		{"ordering", vecType + `print a < b, a > b, a <= b, b >= a, a <= Vec(1, 0)`, "true false true true true\n"},
+		{"sorting with <", vecType + `fn less p, q -> p < q
+xs = {b, a}
+ys = sort(xs, less)
+print xs:0.x`, "1\n"},
+		{"index", vecType + `print a:2, a.x`, "12 1\n"},
+		{"set index", vecType + `a:3 = 4
+print a`, "Vec(x: 7, y: 2)\n"},
+		{"numbers keep their fast path", vecType + `print 2 * 3, 1 < 2, 7 == 7`, "6 true true\n"},
+	})
+	runScriptErrors(t, Options{}, []scriptCase{
+		{"no method", "type V x\nend\nx = V(1) + V(2)", "unsupported operand types for +: 'record' and 'record'"},
+		{"no ordering", "type V x\nend\nx = V(1) < V(2)", "unsupported operand types for <: 'record' and 'record'"},
+	})
+}
@@ -0,0 +1,100 @@
+package aurora
+
+import (
+	"bytes"
+	"errors"
+	"math/rand"
+	"testing"
+)
+
+func TestSandboxLeavesOutAmbientBuiltins(t *testing.T) {
+	vm := NewVM(Options{Sandbox: true})
+	for _, name := range []string{
+		"print",
+		"random", "random_int", "shuffle", "choice",
+		"now", "clock", "sleep",
+		"read_file", "list_dir", "exists", "write_file", "append_file", "make_dir", "delete_file",
+		"env", "args",
+	} {
+		if _, ok := vm.Lookup(name); ok {
+			t.Errorf("sandboxed VM defines %s", name)
+		}
+	}
+	_, err := run(t, Options{Sandbox: true}, "x = now()\n")
+	var runtimeErr *RuntimeError
+	if !errors.As(err, &runtimeErr) || runtimeErr.Kind != KindName {
+		t.Errorf("calling now: got %v, want a name error", err)
+	}
+}
+
+func TestSandboxKeepsWhatTheHostHandsIn(t *testing.T) {
+	var out bytes.Buffer
+	vm, err := run(t, Options{
+		Sandbox:      true,
+		Stdout:       &out,
+		Random:       rand.New(rand.NewSource(1)),
+		Capabilities: Capabilities{Env: []string{"HOME"}},
+	}, "print \"hello\"\nx = random_int(1, 6)\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out.String() != "hello\n" {
+		t.Errorf("print wrote %q", out.String())
+	}
+	if _, ok := vm.Lookup("env"); !ok {
+		t.Error("env is missing although it was granted")
+	}
+}
+
+func TestSandboxDeniesLocalZone(t *testing.T) {
+	_, err := run(t, Options{Sandbox: true}, "x = format_time(0, \"15:04\", \"Local\")\n")
+	if !errors.Is(err, ErrAccessDenied) {
+		t.Errorf("got %v, want ErrAccessDenied", err)
+	}
+	vm, err := run(t, Options{Sandbox: true}, "x = format_time(0, \"15:04\", \"UTC\")\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var x string
+	if err := vm.Get("x", &x); err != nil || x != "00:00" {
+		t.Errorf("x = %q, %v; want 00:00", x, err)
+	}
+}
+
+// Maps iterate in key order, so the same script always produces the same
+// output however Go orders the map underneath.
+func TestSandboxIteratesMapsInOrder(t *testing.T) {
+	src := `keys_seen = {}
+for key, m
+  keys_seen:len(keys_seen) = key
+end
+x = join(keys_seen, ",") + " " + join(keys(m), ",") + " " + json_stringify(m)
+`
+	program, err := Compile(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := map[string]int{}
+	for _, key := range []string{"pear", "apple", "fig", "kiwi", "date", "lime", "plum", "yuzu"} {
+		m[key] = len(key)
+	}
+	const want = "apple,date,fig,kiwi,lime,pear,plum,yuzu " +
+		"apple,date,fig,kiwi,lime,pear,plum,yuzu " +
+		`{"apple":5,"date":4,"fig":3,"kiwi":4,"lime":4,"pear":4,"plum":4,"yuzu":4}`
+	for i := 0; i < 20; i++ {
+		vm := NewVM(Options{Sandbox: true})
+		if err := vm.Set("m", m); err != nil {
+			t.Fatal(err)
+		}
+		if err := vm.Run(program); err != nil {
+			t.Fatal(err)
+		}
+		var x string
+		if err := vm.Get("x", &x); err != nil {
+			t.Fatal(err)
+		}
+		if x != want {
+			t.Fatalf("got %q, want %q", x, want)
+		}
+	}
+}
@@ -0,0 +1,414 @@
+package aurora
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+type ValueKind uint8
+
+const (
+	NilKind ValueKind = iota
+	BoolKind
+	NumberKind
+	StringKind
+	ListKind
+	MapKind
+	FunctionKind
+	NativeKind
+	RegexKind
+	ErrorKind
+	TypeKind
+	RecordKind
+)
+
+var kindNames = [...]string{
+	NilKind:      "nil",
+	BoolKind:     "bool",
+	NumberKind:   "number",
+	StringKind:   "string",
+	ListKind:     "list",
+	MapKind:      "map",
+	FunctionKind: "function",
+	NativeKind:   "native",
+	RegexKind:    "regex",
+	ErrorKind:    "error",
+	TypeKind:     "type",
+	RecordKind:   "record",
+}
+
+func (k ValueKind) String() string {
+	return kindNames[k]
+}
+
+// Value is the VM's representation of every Aurora value. Numbers and
+// booleans live in the number payload so they never touch the heap; strings,
+// lists, maps and functions keep their object in obj.
+//
+// obj is an interface rather than a pointer so that one field holds every
+// object type without unsafe. Apart from strings, the objects are already
+// pointers, so storing one does not allocate, and the As methods check its
+// dynamic type, so a Value whose kind disagrees with its object panics
+// instead of reading the wrong memory. A string is boxed once, when its
+// Value is made, and copying the Value afterwards copies only the box.
+type Value struct {
+	kind   ValueKind
+	number float64
+	obj    any
+}
+
+// ListObject is shared by every Value that refers to the same list, so
+// mutations through one reference are visible through all of them.
+type ListObject struct {
+	items []Value
+}
+
+// MapObject is a string-keyed map, shared by reference like ListObject.
+type MapObject struct {
+	items map[string]Value
+}
+
+var Nil = Value{}
+
+func BoolValue(b bool) Value {
+	if b {
+		return Value{kind: BoolKind, number: 1}
+	}
+	return Value{kind: BoolKind}
+}
+
+func NumberValue(n float64) Value {
+	return Value{kind: NumberKind, number: n}
+}
+
+func StringValue(s string) Value {
+	return Value{kind: StringKind, obj: s}
+}
+
+func ListValue(items []Value) Value {
+	return Value{kind: ListKind, obj: &ListObject{items}}
+}
+
+func MapValue(items map[string]Value) Value {
+	return Value{kind: MapKind, obj: &MapObject{items}}
+}
+
+func FunctionValue(f *AuroraFunction) Value {
+	return Value{kind: FunctionKind, obj: f}
+}
+
+func NativeValue(n *NativeFunction) Value {
+	return Value{kind: NativeKind, obj: n}
+}
+
+func RegexValue(re *regexp.Regexp) Value {
+	return Value{kind: RegexKind, obj: re}
+}
+
+func ErrorValue(e *ErrorObject) Value {
+	return Value{kind: ErrorKind, obj: e}
+}
+
+func (v Value) Kind() ValueKind {
+	return v.kind
+}
+
+func (v Value) AsBool() bool {
+	return v.number != 0
+}
+
+func (v Value) AsNumber() float64 {
+	return v.number
+}
+
+func (v Value) AsString() string {
+	return v.obj.(string)
+}
+
+func (v Value) AsList() *ListObject {
+	return v.obj.(*ListObject)
+}
+
+func (v Value) AsMap() *MapObject {
+	return v.obj.(*MapObject)
+}
+
+func (v Value) AsFunction() *AuroraFunction {
+	return v.obj.(*AuroraFunction)
+}
+
+func (v Value) AsNative() *NativeFunction {
+	return v.obj.(*NativeFunction)
+}
+
+func (v Value) AsRegex() *regexp.Regexp {
+	return v.obj.(*regexp.Regexp)
+}
+
+func (v Value) AsError() *ErrorObject {
+	return v.obj.(*ErrorObject)
+}
+
+// Truthy reports whether v counts as true in a condition: everything except
+// nil and false does.
+func (v Value) Truthy() bool {
+	switch v.kind {
+	case NilKind:
+		return false
+	case BoolKind:
+		return v.AsBool()
+	default:
+		return true
+	}
+}
+
+func valuesEqual(a, b Value) bool {
+	return equalIn(a, b, nil)
+}
+
+// ErrCyclicValue is wrapped by the RuntimeError raised when a list, map or
+// record that contains itself is compared, converted to a string or
+// exported.
+var ErrCyclicValue = errors.New("value contains itself")
+
+// walk holds the lists, maps and records a recursive function over values is
+// inside of, so that it stops at one that contains itself rather than
+// recursing until the Go stack overflows. The nil walk is made on first use.
+type walk map[any]bool
+
+// enter marks v's object as being walked, panicking with a runtime error if
+// it already is. The VM fills in the line and backtrace when it recovers it.
+func (w *walk) enter(v Value, action string) {
+	if *w == nil {
+		*w = walk{}
+	}
+	if (*w)[v.obj] {
+		panic(&RuntimeError{
+			Message: fmt.Sprintf("a %s that contains itself cannot be %s", v.kind, action),
+			Kind:    KindType,
+			Err:     ErrCyclicValue,
+		})
+	}
+	(*w)[v.obj] = true
+}
+
+func (w walk) leave(v Value) {
+	delete(w, v.obj)
+}
+
+func equalIn(a, b Value, w walk) bool {
+	if a.kind != b.kind {
+		return false
+	}
+	switch a.kind {
+	case NilKind:
+		return true
+	case BoolKind, NumberKind:
+		return a.number == b.number
+	case StringKind:
+		return a.AsString() == b.AsString()
+	case ListKind:
+		x, y := a.AsList(), b.AsList()
+		if x == y {
+			return true
+		}
+		if len(x.items) != len(y.items) {
+			return false
+		}
+		w.enter(a, "compared")
+		defer w.leave(a)
+		for i := range x.items {
+			if !equalIn(x.items[i], y.items[i], w) {
+				return false
+			}
+		}
+		return true
+	case MapKind:
+		x, y := a.AsMap(), b.AsMap()
+		if x == y {
+			return true
+		}
+		if len(x.items) != len(y.items) {
+			return false
+		}
+		w.enter(a, "compared")
+		defer w.leave(a)
+		for key, value := range x.items {
+			other, ok := y.items[key]
+			if !ok || !equalIn(value, other, w) {
+				return false
+			}
+		}
+		return true
+	case RegexKind:
+		return a.AsRegex().String() == b.AsRegex().String()
+	case RecordKind:
+		x, y := a.AsRecord(), b.AsRecord()
+		if x == y {
+			return true
+		}
+		if x.typ != y.typ {
+			return false
+		}
+		w.enter(a, "compared")
+		defer w.leave(a)
+		for i := range x.fields {
+			if !equalIn(x.fields[i], y.fields[i], w) {
+				return false
+			}
+		}
+		return true
+	default:
+		return a.obj == b.obj
+	}
+}
+
+// Export returns v as a plain Go value: nil, bool, float64, string, []any or
+// map[string]any. Records are returned as a map of their fields, regexes as
+// *regexp.Regexp, and functions, types and errors as their Value. Export
+// panics with a *RuntimeError wrapping ErrCyclicValue if v contains itself.
+func (v Value) Export() any {
+	return v.exportIn(nil)
+}
+
+func (v Value) exportIn(w walk) any {
+	switch v.kind {
+	case NilKind:
+		return nil
+	case BoolKind:
+		return v.AsBool()
+	case NumberKind:
+		return v.number
+	case StringKind:
+		return v.AsString()
+	case ListKind:
+		w.enter(v, "exported")
+		defer w.leave(v)
+		items := make([]any, len(v.AsList().items))
+		for i, item := range v.AsList().items {
+			items[i] = item.exportIn(w)
+		}
+		return items
+	case MapKind:
+		w.enter(v, "exported")
+		defer w.leave(v)
+		items := make(map[string]any, len(v.AsMap().items))
+		for key, item := range v.AsMap().items {
+			items[key] = item.exportIn(w)
+		}
+		return items
+	case RegexKind:
+		return v.AsRegex()
+	case RecordKind:
+		w.enter(v, "exported")
+		defer w.leave(v)
+		r := v.AsRecord()
+		items := make(map[string]any, len(r.fields))
+		for i, field := range r.typ.fields {
+			items[field] = r.fields[i].exportIn(w)
+		}
+		return items
+	}
+	return v
+}
+
+// sortedKeys returns the keys of m in ascending order.
+func (m *MapObject) sortedKeys() []string {
+	keys := make([]string, 0, len(m.items))
+	for key := range m.items {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// repr is String, except that strings are quoted.
+func (v Value) repr() string {
+	if v.kind == StringKind {
+		return strconv.Quote(v.AsString())
+	}
+	return v.String()
+}
+
+// String formats v as print shows it. Like Export, it panics if v contains
+// itself.
+func (v Value) String() string {
+	switch v.kind {
+	case NilKind:
+		return "nil"
+	case BoolKind:
+		return strconv.FormatBool(v.AsBool())
+	case NumberKind:
+		return strconv.FormatFloat(v.number, 'g', -1, 64)
+	case StringKind:
+		return v.AsString()
+	case ListKind, MapKind, RecordKind:
+		var sb strings.Builder
+		v.writeIn(&sb, nil)
+		return sb.String()
+	case FunctionKind:
+		return "<fn " + v.AsFunction().name + ">"
+	case NativeKind:
+		return "<native fn " + v.AsNative().Name + ">"
+	case RegexKind:
+		return "<regex " + v.AsRegex().String() + ">"
+	case ErrorKind:
+		return v.AsError().Message
+	case TypeKind:
+		return "<type " + v.AsType().name + ">"
+	}
+	return "<unknown>"
+}
+
+// writeIn writes the repr of v to sb, writing the items of lists, maps and
+// records in turn.
+func (v Value) writeIn(sb *strings.Builder, w walk) {
+	switch v.kind {
+	case ListKind:
+		w.enter(v, "converted to a string")
+		defer w.leave(v)
+		sb.WriteByte('{')
+		for i, item := range v.AsList().items {
+			if i > 0 {
+				sb.WriteString(", ")
+			}
+			item.writeIn(sb, w)
+		}
+		sb.WriteByte('}')
+	case MapKind:
+		w.enter(v, "converted to a string")
+		defer w.leave(v)
+		m := v.AsMap()
+		sb.WriteByte('{')
+		for i, key := range m.sortedKeys() {
+			if i > 0 {
+				sb.WriteString(", ")
+			}
+			sb.WriteString(strconv.Quote(key))
+			sb.WriteString(": ")
+			m.items[key].writeIn(sb, w)
+		}
+		sb.WriteByte('}')
+	case RecordKind:
+		w.enter(v, "converted to a string")
+		defer w.leave(v)
+		r := v.AsRecord()
+		sb.WriteString(r.typ.name)
+		sb.WriteByte('(')
+		for i, field := range r.typ.fields {
+			if i > 0 {
+				sb.WriteString(", ")
+			}
+			sb.WriteString(field)
+			sb.WriteString(": ")
+			r.fields[i].writeIn(sb, w)
+		}
+		sb.WriteByte(')')
+	default:
+		sb.WriteString(v.repr())
+	}
+}
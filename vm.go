@@ -1,84 +1,168 @@
-package main
+package aurora
 
-import "math"
+import (
+	"context"
+	"io"
+	"math"
+	"math/rand"
+	"regexp"
+	"strings"
+	"time"
+)
 
-type ChunkType int
+type chunkType int
 
 const (
-	TypeProgram ChunkType = iota
-	TypeFunction
-	TypeSubroutine
+	typeProgram chunkType = iota
+	typeFunction
+	typeSubroutine
 )
 
-type Chunk struct {
+type chunk struct {
 	code      []byte
 	lines     []int
-	constants []any
+	constants []Value
+	locals    int       // local slots used by the chunk
+	registers int       // registers used by the chunk
+	handlers  []handler // innermost first
+}
+
+// handler catches runtime errors raised while a frame's pc is in
+// (start, end], that is, by an instruction starting in [start, end). It
+// jumps to target with the error in register.
+type handler struct {
+	start, end, target int
+	register           uint8
 }
 
 type AuroraFunction struct {
 	name  string
 	args  []string
 	arity int
-	body  *Chunk
+	body  *chunk
 }
 
-type CallFrame struct {
-	locals    [256]any
-	function  AuroraFunction
+// callFrame is one activation of a function. Its locals and registers are
+// consecutive windows of the VM's value stack starting at base.
+type callFrame struct {
+	locals    []Value
+	registers []Value
+	base      int
+	function  *AuroraFunction
 	pc        int
 	dest      uint8
-	chunkType ChunkType
+	chunkType chunkType
+	entry     bool // pushed by the host, which receives the return value
 }
 
 // register-based virtual machine
 type AuroraVM struct {
-	registers [256]*any
-	callStack []CallFrame
-	globals   map[int]any
+	stack        []Value
+	callStack    []callFrame
+	globals      map[string]Value
+	result       Value
+	maxCallDepth int // zero means unlimited
+	converter    converter
+	stdout       io.Writer
+	random       *rand.Rand
+	fs           sandboxFS
+	regexes      map[string]*regexp.Regexp // compiled patterns, by source
+	ctx          context.Context           // of the current run
+	start        time.Time                 // creation time, for clock
+	sandbox      bool
+
+	// accounting for the current run; see limits.go
+	maxInstructions int64
+	maxMemory       int64
+	steps           int64
+	nextCheck       int64
+	allocated       int64
 }
 
-func NewAuroraVM(chunk *Chunk) *AuroraVM {
-	return &AuroraVM{
-		registers: [256]*any{},
-		callStack: []CallFrame{
-			{[256]any{}, AuroraFunction{"[script]", []string{}, 0, chunk}, 0, 0, TypeProgram},
-		},
-		globals: map[int]any{},
+// pushFrame reserves a fresh window of the value stack for function and
+// makes it the current frame.
+func (vm *AuroraVM) pushFrame(function *AuroraFunction, dest uint8, chunkType chunkType) *callFrame {
+	base := len(vm.stack)
+	size := function.body.locals + function.body.registers
+	if base+size > cap(vm.stack) {
+		stack := make([]Value, base, 2*cap(vm.stack)+size)
+		copy(stack, vm.stack)
+		vm.stack = stack
+		for i := range vm.callStack {
+			vm.callStack[i].window(vm.stack)
+		}
+	}
+	vm.stack = vm.stack[:base+size]
+	for i := base; i < len(vm.stack); i++ {
+		vm.stack[i] = Nil
 	}
+	vm.callStack = append(vm.callStack, callFrame{
+		base:      base,
+		function:  function,
+		dest:      dest,
+		chunkType: chunkType,
+	})
+	frame := &vm.callStack[len(vm.callStack)-1]
+	frame.window(vm.stack)
+	return frame
 }
 
-type Opcode uint8
+func (frame *callFrame) window(stack []Value) {
+	locals := frame.base + frame.function.body.locals
+	frame.locals = stack[frame.base:locals:locals]
+	frame.registers = stack[locals : locals+frame.function.body.registers]
+}
+
+func (vm *AuroraVM) popFrame() callFrame {
+	frame := vm.callStack[len(vm.callStack)-1]
+	vm.callStack = vm.callStack[:len(vm.callStack)-1]
+	vm.stack = vm.stack[:frame.base]
+	return frame
+}
+
+type opcode uint8
 
 const (
-	OpLoad           Opcode = iota // LOAD <constant> <register>
-	OpStore                        // STORE <register> <local>
-	OpStoreGlobal                  // STOREGLOBAL <register> <global>
-	OpAdd                          // ADD <register (a)> <register (b)> <register (dest)>
-	OpAddTo                        // ADDTO <register (a)> <register (b)>
-	OpSub                          // SUB <register (a)> <register (b)> <register (dest)>
-	OpSubFrom                      // SUBFROM <register (a)> <register (b)>
-	OpMul                          // MUL <register (a)> <register (b)> <register (dest)>
-	OpDiv                          // DIV <register (a)> <register (b)> <register (dest)>
-	OpMod                          // MOD <register (a)> <register (b)> <register (dest)>
-	OpNeg                          // NEG <register (a)> <register (dest)>
-	OpNot                          // NOT <register (a)> <register (dest)>
-	OpEqual                        // EQUAL <register (a)> <register (b)> <register (dest)>
-	OpNotEqual                     // NOTEQUAL <register (a)> <register (b)> <register (dest)>
-	OpLess                         // LESS <register (a)> <register (b)> <register (dest)>
-	OpLessEqual                    // LESSEQUAL <register (a)> <register (b)> <register (dest)>
-	OpGreater                      // GREATER <register (a)> <register (b)> <register (dest)>
-	OpGreaterEqual                 // GREATEREQUAL <register (a)> <register (b)> <register (dest)>
-	OpJump                         // JUMP <short offset>
-	OpJumpIfFalse                  // JUMPIFFALSE <register (a)> <short offset>
-	OpJumpIfTrue                   // JUMPIFTRUE <register (a)> <short offset>
-	OpJumpIfEqual                  // JUMPIFEQUAL <register (a)> <register (b)> <short offset>
-	OpJumpIfNotEqual               // JUMPIFNOTEQUAL <register (a)> <register (b)> <short offset>
-	OpLoop                         // LOOP <short offset>
-	OpCall                         // CALL <register (func)> <register (n args)> <register (base of args)> <register (dest)>
-	OpReturn                       // RETURN <register (a)>
-	OpIndex                        // INDEX <register (a)> <register (b)> <register (dest)>
-	OpIndexAssign                  // INDEXASSIGN <register (a)> <register (b)> <register (c)>
+	opLoad           opcode = iota // LOAD <constant> <register>
+	opStore                        // STORE <register> <local>
+	opStoreGlobal                  // STOREGLOBAL <register> <constant (name)>
+	opLoadLocal                    // LOADLOCAL <local> <register>
+	opLoadGlobal                   // LOADGLOBAL <constant (name)> <register>
+	opAdd                          // ADD <register (a)> <register (b)> <register (dest)>
+	opAddTo                        // ADDTO <register (a)> <register (b)>
+	opSub                          // SUB <register (a)> <register (b)> <register (dest)>
+	opSubFrom                      // SUBFROM <register (a)> <register (b)>
+	opMul                          // MUL <register (a)> <register (b)> <register (dest)>
+	opDiv                          // DIV <register (a)> <register (b)> <register (dest)>
+	opMod                          // MOD <register (a)> <register (b)> <register (dest)>
+	opNeg                          // NEG <register (a)> <register (dest)>
+	opNot                          // NOT <register (a)> <register (dest)>
+	opEqual                        // EQUAL <register (a)> <register (b)> <register (dest)>
+	opNotEqual                     // NOTEQUAL <register (a)> <register (b)> <register (dest)>
+	opLess                         // LESS <register (a)> <register (b)> <register (dest)>
+	opLessEqual                    // LESSEQUAL <register (a)> <register (b)> <register (dest)>
+	opGreater                      // GREATER <register (a)> <register (b)> <register (dest)>
+	opGreaterEqual                 // GREATEREQUAL <register (a)> <register (b)> <register (dest)>
+	opJump                         // JUMP <short offset>
+	opJumpIfFalse                  // JUMPIFFALSE <register (a)> <short offset>
+	opJumpIfTrue                   // JUMPIFTRUE <register (a)> <short offset>
+	opJumpIfEqual                  // JUMPIFEQUAL <register (a)> <register (b)> <short offset>
+	opJumpIfNotEqual               // JUMPIFNOTEQUAL <register (a)> <register (b)> <short offset>
+	opLoop                         // LOOP <short offset>
+	opCall                         // CALL <register (func)> <register (n args)> <register (base of args)> <register (dest)>
+	opReturn                       // RETURN <register (a)>
+	opIndex                        // INDEX <register (a)> <register (b)> <register (dest)>
+	opIndexAssign                  // INDEXASSIGN <register (a)> <register (b)> <register (c)>
+	opList                         // LIST <register (base of items)> <n items> <register (dest)>
+	opIterate                      // ITERATE <register (list)> <register (index)> <register (dest)> <short offset>
+	opThrow                        // THROW <register (a)>
+	opSlice                        // SLICE <register (a)> <register (start)> <register (end)> <register (dest)>
+	opUnpack                       // UNPACK <register (list)> <n items> <register (base of dest)>
+	opJumpIfNotKind                // JUMPIFNOTKIND <register (a)> <kind> <short offset>
+	opJumpIfNotLen                 // JUMPIFNOTLEN <register (list)> <n items> <at least> <short offset>
+	opJumpIfNoKey                  // JUMPIFNOKEY <register (map)> <register (key)> <short offset>
+	opType                         // TYPE <register (name, then field names, then methods)> <n fields> <n methods> <register (dest)>
+	opMethod                       // METHOD <register (receiver)> <constant (name)> <register (dest)>
 )
 
 func (vm *AuroraVM) readByte() byte {
@@ -87,212 +171,386 @@ func (vm *AuroraVM) readByte() byte {
 	return frame.function.body.code[frame.pc-1]
 }
 
-func (vm *AuroraVM) readConstant() any {
+func (vm *AuroraVM) readConstant() Value {
 	frame := &vm.callStack[len(vm.callStack)-1]
 	return frame.function.body.constants[vm.readByte()]
 }
 
 func (vm *AuroraVM) readShort() uint16 {
-	return uint16(vm.readByte()) | uint16(vm.readByte())<<8
+	return uint16(vm.readByte())<<8 | uint16(vm.readByte())
+}
+
+// setRegister stores into a register of the current frame. Instructions
+// that may have called back into the VM use it rather than the registers
+// they started with, which a call may have moved.
+func (vm *AuroraVM) setRegister(register uint8, value Value) {
+	vm.callStack[len(vm.callStack)-1].registers[register] = value
 }
 
-func (vm *AuroraVM) Step() {
+func (vm *AuroraVM) step() {
 	frame := &vm.callStack[len(vm.callStack)-1]
-	instruction := Opcode(vm.readByte())
+	regs := frame.registers
+	instruction := opcode(vm.readByte())
 	switch instruction {
-	case OpLoad:
+	case opLoad:
 		constant := vm.readConstant()
 		register := vm.readByte()
-		vm.registers[register] = &constant
-	case OpStore:
+		regs[register] = constant
+	case opStore:
 		register := vm.readByte()
 		local := vm.readByte()
-		frame.locals[local] = register
-	case OpStoreGlobal:
+		frame.locals[local] = regs[register]
+	case opStoreGlobal:
 		register := vm.readByte()
-		global := vm.readByte()
-		vm.globals[int(global)] = *vm.registers[register]
-	case OpAdd:
-		a := vm.readByte()
-		b := vm.readByte()
-		dest := vm.readByte()
-		switch (*vm.registers[a]).(type) {
-		case float64:
-			*vm.registers[dest] = (*vm.registers[a]).(float64) + (*vm.registers[b]).(float64)
-		}
-	case OpAddTo:
-		a := vm.readByte()
-		b := vm.readByte()
-		switch (*vm.registers[a]).(type) {
-		case float64:
-			*vm.registers[a] = (*vm.registers[a]).(float64) + (*vm.registers[b]).(float64)
-		}
-	case OpSub:
-		a := vm.readByte()
-		b := vm.readByte()
-		dest := vm.readByte()
-		switch (*vm.registers[a]).(type) {
-		case float64:
-			*vm.registers[dest] = (*vm.registers[a]).(float64) - (*vm.registers[b]).(float64)
-		}
-	case OpSubFrom:
-		a := vm.readByte()
-		b := vm.readByte()
-		switch (*vm.registers[a]).(type) {
-		case float64:
-			*vm.registers[a] = (*vm.registers[a]).(float64) - (*vm.registers[b]).(float64)
-		}
-	case OpMul:
-		a := vm.readByte()
-		b := vm.readByte()
-		dest := vm.readByte()
-		switch (*vm.registers[a]).(type) {
-		case float64:
-			*vm.registers[dest] = (*vm.registers[a]).(float64) * (*vm.registers[b]).(float64)
+		name := vm.readConstant().AsString()
+		vm.globals[name] = regs[register]
+	case opLoadLocal:
+		local := vm.readByte()
+		register := vm.readByte()
+		regs[register] = frame.locals[local]
+	case opLoadGlobal:
+		name := vm.readConstant().AsString()
+		register := vm.readByte()
+		value, ok := vm.globals[name]
+		if !ok {
+			vm.fail(KindName, nil, "undefined variable '%s'", name)
 		}
-	case OpDiv:
+		regs[register] = value
+	case opAdd, opSub, opMul, opDiv, opMod:
 		a := vm.readByte()
 		b := vm.readByte()
 		dest := vm.readByte()
-		switch (*vm.registers[a]).(type) {
-		case float64:
-			*vm.registers[dest] = (*vm.registers[a]).(float64) / (*vm.registers[b]).(float64)
+		x, y := regs[a], regs[b]
+		if x.kind == NumberKind && y.kind == NumberKind {
+			switch instruction {
+			case opAdd:
+				regs[dest] = NumberValue(x.number + y.number)
+			case opSub:
+				regs[dest] = NumberValue(x.number - y.number)
+			case opMul:
+				regs[dest] = NumberValue(x.number * y.number)
+			case opDiv:
+				regs[dest] = NumberValue(x.number / y.number)
+			case opMod:
+				regs[dest] = NumberValue(math.Mod(x.number, y.number))
+			}
+		} else {
+			vm.setRegister(dest, vm.arithmetic(instruction, x, y))
 		}
-	case OpMod:
+	case opAddTo, opSubFrom:
 		a := vm.readByte()
 		b := vm.readByte()
-		dest := vm.readByte()
-		switch (*vm.registers[a]).(type) {
-		case float64:
-			*vm.registers[dest] = math.Mod((*vm.registers[a]).(float64), (*vm.registers[b]).(float64))
-		}
-	case OpNeg:
+		vm.setRegister(a, vm.arithmetic(instruction, regs[a], regs[b]))
+	case opNeg:
 		a := vm.readByte()
 		dest := vm.readByte()
-		switch (*vm.registers[a]).(type) {
-		case float64:
-			*vm.registers[dest] = -(*vm.registers[a]).(float64)
+		if regs[a].kind == NumberKind {
+			regs[dest] = NumberValue(-regs[a].number)
+		} else if result, ok := vm.overload("__neg", regs[a]); ok {
+			vm.setRegister(dest, result)
+		} else {
+			vm.fail(KindType, nil, "unsupported operand type for unary -: '%s'", regs[a].kind)
 		}
-	case OpNot:
+	case opNot:
 		a := vm.readByte()
 		dest := vm.readByte()
-		switch (*vm.registers[a]).(type) {
-		case bool:
-			*vm.registers[dest] = !(*vm.registers[a]).(bool)
-		}
-	case OpEqual:
+		regs[dest] = BoolValue(!regs[a].Truthy())
+	case opEqual, opNotEqual:
 		a := vm.readByte()
 		b := vm.readByte()
 		dest := vm.readByte()
-		*vm.registers[dest] = *vm.registers[a] == *vm.registers[b]
-	case OpNotEqual:
+		equal := vm.equal(regs[a], regs[b])
+		vm.setRegister(dest, BoolValue(equal == (instruction == opEqual)))
+	case opLess, opLessEqual, opGreater, opGreaterEqual:
 		a := vm.readByte()
 		b := vm.readByte()
 		dest := vm.readByte()
-		*vm.registers[dest] = *vm.registers[a] != *vm.registers[b]
-	case OpLess:
-		a := vm.readByte()
-		b := vm.readByte()
-		dest := vm.readByte()
-		switch (*vm.registers[a]).(type) {
-		case float64:
-			*vm.registers[dest] = (*vm.registers[a]).(float64) < (*vm.registers[b]).(float64)
+		result := vm.compare(instruction, regs[a], regs[b])
+		vm.setRegister(dest, BoolValue(result))
+	case opJump:
+		offset := vm.readShort()
+		frame.pc += int(offset)
+	case opJumpIfFalse:
+		register := vm.readByte()
+		offset := vm.readShort()
+		if !regs[register].Truthy() {
+			frame.pc += int(offset)
 		}
-	case OpLessEqual:
-		a := vm.readByte()
-		b := vm.readByte()
-		dest := vm.readByte()
-		switch (*vm.registers[a]).(type) {
-		case float64:
-			*vm.registers[dest] = (*vm.registers[a]).(float64) <= (*vm.registers[b]).(float64)
+	case opJumpIfTrue:
+		register := vm.readByte()
+		offset := vm.readShort()
+		if regs[register].Truthy() {
+			frame.pc += int(offset)
 		}
-	case OpGreater:
+	case opJumpIfEqual:
 		a := vm.readByte()
 		b := vm.readByte()
-		dest := vm.readByte()
-		switch (*vm.registers[a]).(type) {
-		case float64:
-			*vm.registers[dest] = (*vm.registers[a]).(float64) > (*vm.registers[b]).(float64)
+		offset := vm.readShort()
+		if valuesEqual(regs[a], regs[b]) {
+			frame.pc += int(offset)
 		}
-	case OpGreaterEqual:
+	case opJumpIfNotEqual:
 		a := vm.readByte()
 		b := vm.readByte()
-		dest := vm.readByte()
-		switch (*vm.registers[a]).(type) {
-		case float64:
-			*vm.registers[dest] = (*vm.registers[a]).(float64) >= (*vm.registers[b]).(float64)
-		}
-	case OpJump:
-		offset := vm.readShort()
-		frame.pc += int(offset)
-	case OpJumpIfFalse:
 		offset := vm.readShort()
-		register := vm.readByte()
-		if !(*vm.registers[register]).(bool) {
+		if !valuesEqual(regs[a], regs[b]) {
 			frame.pc += int(offset)
 		}
-	case OpJumpIfTrue:
+	case opJumpIfNotKind:
+		a := vm.readByte()
+		kind := ValueKind(vm.readByte())
 		offset := vm.readShort()
-		register := vm.readByte()
-		if (*vm.registers[register]).(bool) {
+		if regs[a].kind != kind {
 			frame.pc += int(offset)
 		}
-	case OpJumpIfEqual:
+	case opJumpIfNotLen:
+		list := vm.readByte()
+		n := int(vm.readByte())
+		atLeast := vm.readByte() != 0
 		offset := vm.readShort()
-		a := vm.readByte()
-		b := vm.readByte()
-		if *vm.registers[a] == *vm.registers[b] {
+		if regs[list].kind != ListKind {
+			frame.pc += int(offset)
+		} else if length := len(regs[list].AsList().items); length != n && !(atLeast && length > n) {
 			frame.pc += int(offset)
 		}
-	case OpJumpIfNotEqual:
+	case opJumpIfNoKey:
+		m := vm.readByte()
+		key := vm.readByte()
 		offset := vm.readShort()
-		a := vm.readByte()
-		b := vm.readByte()
-		if *vm.registers[a] != *vm.registers[b] {
+		if regs[m].kind != MapKind {
+			frame.pc += int(offset)
+		} else if _, ok := regs[m].AsMap().items[regs[key].String()]; !ok {
 			frame.pc += int(offset)
 		}
-	case OpLoop:
+	case opType:
+		base := int(vm.readByte())
+		nFields := int(vm.readByte())
+		nMethods := int(vm.readByte())
+		dest := vm.readByte()
+		for _, name := range regs[base : base+1+nFields] {
+			if name.kind != StringKind {
+				vm.fail(KindType, nil, "type and field names must be strings, not %s", name.kind)
+			}
+		}
+		t := &RecordType{regs[base].AsString(), make([]string, nFields), map[string]Value{}}
+		for i := range t.fields {
+			t.fields[i] = regs[base+1+i].AsString()
+		}
+		for _, method := range regs[base+1+nFields : base+1+nFields+nMethods] {
+			if method.kind != FunctionKind {
+				vm.fail(KindType, nil, "methods must be functions, not %s", method.kind)
+			}
+			// methods are compiled with the type name in front of theirs
+			t.methods[strings.TrimPrefix(method.AsFunction().name, t.name+".")] = method
+		}
+		regs[dest] = TypeValue(t)
+	case opMethod:
+		receiver := vm.readByte()
+		name := vm.readConstant().AsString()
+		dest := vm.readByte()
+		regs[dest] = vm.method(regs[receiver], name)
+	case opLoop:
 		offset := vm.readShort()
 		frame.pc -= int(offset)
-	case OpCall: // f a b d
+	case opCall: // f a b d
 		function := vm.readByte()
 		arity := vm.readByte()
 		registerBase := vm.readByte()
 		dest := vm.readByte()
-		funcObj := (*vm.registers[function]).(AuroraFunction)
-		if int(arity) != funcObj.arity {
-			panic("Arity mismatch")
+		switch regs[function].kind {
+		case FunctionKind:
+		case NativeKind:
+			args := make([]Value, arity)
+			copy(args, regs[registerBase:int(registerBase)+int(arity)])
+			result := vm.callNative(regs[function].AsNative(), args)
+			// the native may have re-entered the VM and moved the stack
+			vm.callStack[len(vm.callStack)-1].registers[dest] = result
+			return
+		case TypeKind:
+			record, err := newRecord(regs[function].AsType(), regs[registerBase:int(registerBase)+int(arity)])
+			if err != nil {
+				vm.fail(KindCall, nil, "%v", err)
+			}
+			vm.allocate(sizeOf(record))
+			regs[dest] = record
+			return
+		default:
+			vm.fail(KindType, nil, "'%s' is not callable", regs[function].kind)
 		}
-		frame := CallFrame{
-			function:  funcObj,
-			pc:        0,
-			locals:    [256]any{},
-			dest:      dest,
-			chunkType: TypeFunction,
+		funcObj := regs[function].AsFunction()
+		if int(arity) != funcObj.arity {
+			vm.fail(KindCall, nil, "%s expects %d arguments, got %d", funcObj.name, funcObj.arity, arity)
 		}
-		for i := 0; i < int(arity); i++ {
-			frame.locals[i] = vm.registers[registerBase+byte(i)]
+		vm.checkCallDepth()
+		callee := vm.pushFrame(funcObj, dest, typeFunction)
+		copy(callee.locals, vm.callStack[len(vm.callStack)-2].registers[registerBase:int(registerBase)+int(arity)])
+	case opReturn:
+		value := regs[vm.readByte()]
+		returned := vm.popFrame()
+		if returned.entry {
+			vm.result = value
+		} else {
+			vm.callStack[len(vm.callStack)-1].registers[returned.dest] = value
 		}
-		vm.callStack = append(vm.callStack, frame)
-	case OpReturn:
-		frame := vm.callStack[len(vm.callStack)-1]
-		vm.callStack = vm.callStack[:len(vm.callStack)-1]
-		*vm.registers[frame.dest] = vm.registers[vm.readByte()]
-	case OpIndex:
+	case opIndex:
 		a := vm.readByte()
 		b := vm.readByte()
 		dest := vm.readByte()
-		switch (*vm.registers[a]).(type) {
-		case []any:
-			*vm.registers[dest] = (*vm.registers[a]).([]any)[(*vm.registers[b]).(int)]
+		switch regs[a].kind {
+		case ListKind:
+			items := regs[a].AsList().items
+			regs[dest] = items[vm.listIndex(regs[b], len(items))]
+		case StringKind:
+			regs[dest] = vm.indexString(regs[a].AsString(), regs[b])
+		case MapKind:
+			regs[dest] = regs[a].AsMap().items[regs[b].String()]
+		case ErrorKind:
+			regs[dest] = errorField(regs[a].AsError(), regs[b])
+		case RecordKind:
+			vm.setRegister(dest, vm.recordField(regs[a].AsRecord(), regs[b]))
+		default:
+			vm.fail(KindType, nil, "cannot index a %s", regs[a].kind)
 		}
-	case OpIndexAssign:
+	case opIndexAssign:
+		// Assigning at a list's length appends; any other index must be in
+		// range. Maps take any string key and strings cannot be assigned to.
 		a := vm.readByte()
 		b := vm.readByte()
 		c := vm.readByte()
-		switch (*vm.registers[a]).(type) {
-		case []any:
-			(*vm.registers[a]).([]any)[(*vm.registers[b]).(int)] = *vm.registers[c]
+		switch regs[a].kind {
+		case ListKind:
+			list := regs[a].AsList()
+			if regs[b].kind == NumberKind && regs[b].number == float64(len(list.items)) {
+				vm.allocate(valueSize)
+				list.items = append(list.items, regs[c])
+			} else {
+				list.items[vm.listIndex(regs[b], len(list.items))] = regs[c]
+			}
+		case StringKind:
+			vm.fail(KindType, nil, "strings are immutable")
+		case MapKind:
+			items, key := regs[a].AsMap().items, regs[b].String()
+			if _, ok := items[key]; !ok {
+				vm.allocate(int64(len(key)) + valueSize)
+			}
+			items[key] = regs[c]
+		case RecordKind:
+			vm.setRecordField(regs[a].AsRecord(), regs[b], regs[c])
+		default:
+			vm.fail(KindType, nil, "cannot assign to an index of a %s", regs[a].kind)
+		}
+	case opList:
+		base := vm.readByte()
+		n := vm.readByte()
+		dest := vm.readByte()
+		vm.allocate(int64(n) * valueSize)
+		items := make([]Value, n)
+		copy(items, regs[base:int(base)+int(n)])
+		regs[dest] = ListValue(items)
+	case opIterate:
+		list := vm.readByte()
+		index := vm.readByte()
+		dest := vm.readByte()
+		offset := vm.readShort()
+		if regs[list].kind == MapKind {
+			// iterate over a snapshot of the keys, in a reproducible order
+			regs[list] = stringList(regs[list].AsMap().sortedKeys())
+		} else if regs[list].kind != ListKind {
+			vm.fail(KindType, nil, "cannot iterate over a %s", regs[list].kind)
+		}
+		items := regs[list].AsList().items
+		i := int(regs[index].number)
+		if i < 0 || i >= len(items) {
+			frame.pc += int(offset)
+		} else {
+			regs[dest] = items[i]
+			regs[index] = NumberValue(float64(i + 1))
+		}
+	case opThrow:
+		vm.throw(regs[vm.readByte()])
+	case opSlice:
+		a := vm.readByte()
+		start := vm.readByte()
+		end := vm.readByte()
+		dest := vm.readByte()
+		regs[dest] = vm.slice(regs[a], regs[start], regs[end])
+	case opUnpack:
+		list := vm.readByte()
+		n := int(vm.readByte())
+		base := vm.readByte()
+		if regs[list].kind != ListKind {
+			vm.fail(KindType, nil, "cannot unpack a %s", regs[list].kind)
 		}
+		items := regs[list].AsList().items
+		if len(items) != n {
+			vm.fail(KindIndex, nil, "cannot unpack %d values into %d names", len(items), n)
+		}
+		copy(regs[base:int(base)+n], items)
+	}
+}
+
+func (vm *AuroraVM) checkCallDepth() {
+	if vm.maxCallDepth > 0 && len(vm.callStack) >= vm.maxCallDepth {
+		vm.raise(ErrStackOverflow, "stack overflow: more than %d nested calls", vm.maxCallDepth)
 	}
 }
+
+// execute runs function to completion on top of whatever is already on the
+// call stack and returns its result. A runtime error that no try block in
+// the frames this call pushed handles unwinds only those frames.
+func (vm *AuroraVM) execute(function *AuroraFunction, args []Value, chunkType chunkType) (Value, error) {
+	depth := len(vm.callStack)
+	base := len(vm.stack)
+	if depth == 0 {
+		vm.resetBudget()
+	}
+	err := protect(func() {
+		vm.checkCallDepth()
+		frame := vm.pushFrame(function, 0, chunkType)
+		frame.entry = true
+		copy(frame.locals, args)
+	})
+	for err == nil {
+		if err = protect(func() { vm.dispatch(depth) }); err == nil {
+			result := vm.result
+			vm.result = Nil
+			return result, nil
+		}
+		if err.Trace == nil {
+			// raised outside the VM, such as by walking a value that
+			// contains itself
+			err.Line, err.Trace = vm.currentLine(), vm.backtrace()
+		}
+		if vm.catch(err, depth) {
+			err = nil
+		}
+	}
+	vm.callStack = vm.callStack[:depth]
+	vm.stack = vm.stack[:base]
+	return Nil, err
+}
+
+// dispatch steps until the frames above depth have returned.
+func (vm *AuroraVM) dispatch(depth int) {
+	for len(vm.callStack) > depth {
+		if vm.steps++; vm.steps >= vm.nextCheck {
+			vm.checkBudget()
+		}
+		vm.step()
+	}
+}
+
+// protect calls fn and returns the runtime error it raises, if any.
+func protect(fn func()) (err *RuntimeError) {
+	defer func() {
+		if r := recover(); r != nil {
+			runtimeErr, ok := r.(*RuntimeError)
+			if !ok {
+				panic(r)
+			}
+			err = runtimeErr
+		}
+	}()
+	fn()
+	return nil
+}
@@ -0,0 +1,117 @@
+package aurora
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestRecursion(t *testing.T) {
+	vm, err := run(t, Options{}, `fn fib n
+  if n < 2
+    return n
+  end
+  return fib(n - 1) + fib(n - 2)
+end
+x = fib(20)
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var x int
+	if err := vm.Get("x", &x); err != nil || x != 6765 {
+		t.Errorf("fib(20) = %d, %v; want 6765", x, err)
+	}
+}
+
+func TestMutualRecursion(t *testing.T) {
+	vm, err := run(t, Options{}, `fn is_even n
+  if n == 0
+    return true
+  end
+  return is_odd(n - 1)
+end
+fn is_odd n
+  if n == 0
+    return false
+  end
+  return is_even(n - 1)
+end
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for n, want := range map[int]bool{0: true, 1: false, 10: true, 777: false} {
+		got, err := vm.Call("is_even", n)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got.AsBool() != want {
+			t.Errorf("is_even(%d) = %v, want %v", n, got, want)
+		}
+	}
+}
+
+func TestDeepCallStack(t *testing.T) {
+	vm, err := run(t, Options{}, `fn sum n
+  if n == 0
+    return 0
+  end
+  return n + sum(n - 1)
+end
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// sum(n) takes n + 1 frames, so this fills the stack to the limit
+	depth := DefaultMaxCallDepth - 1
+	got, err := vm.Call("sum", depth)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := float64(depth * (depth + 1) / 2); got.AsNumber() != want {
+		t.Errorf("sum(%d) = %v, want %v", depth, got, want)
+	}
+}
+
+func TestStackOverflow(t *testing.T) {
+	_, err := run(t, Options{MaxCallDepth: 100}, `fn down n
+  return down(n + 1)
+end
+down(0)
+`)
+	if !errors.Is(err, ErrStackOverflow) {
+		t.Fatalf("got %v, want ErrStackOverflow", err)
+	}
+	var runtimeErr *RuntimeError
+	if !errors.As(err, &runtimeErr) || runtimeErr.Kind != KindStack {
+		t.Fatalf("got %#v, want a RuntimeError of kind %q", err, KindStack)
+	}
+	if len(runtimeErr.Trace) != 100 {
+		t.Errorf("trace has %d frames, want 100", len(runtimeErr.Trace))
+	}
+	lines := strings.Split(err.Error(), "\n")
+	if want := 1 + backtraceHead + 1 + backtraceTail; len(lines) != want {
+		t.Errorf("error has %d lines, want %d:\n%s", len(lines), want, err)
+	}
+	if more := lines[1+backtraceHead]; more != "  ... 87 more frames ..." {
+		t.Errorf("got %q, want the number of frames left out", more)
+	}
+	if last := lines[len(lines)-1]; last != "  in [script] at line 4" {
+		t.Errorf("got %q, want the top level last", last)
+	}
+}
+
+func TestStackOverflowAtDefaultDepth(t *testing.T) {
+	vm, err := run(t, Options{}, "fn down n\n  return down(n + 1)\nend\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := vm.Call("down", 0); !errors.Is(err, ErrStackOverflow) {
+		t.Fatalf("got %v, want ErrStackOverflow", err)
+	}
+	// the VM is still usable afterwards
+	if _, err := vm.Call("len", "abc"); err != nil {
+		t.Fatal(err)
+	}
+}